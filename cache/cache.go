@@ -42,10 +42,10 @@ func NewStorage() *Storage {
 	}
 }
 
-//Get a cached content by key
+//Get a cached content by key, expired content is deleted so Get takes the write lock
 func (s Storage) Get(key string) []byte {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	item := s.items[key]
 	if item.Expired() {
@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorageGetExpiredConcurrently(t *testing.T) {
+	s := NewStorage()
+	s.Set("/", []byte("page"), -time.Second)
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := s.Get("/"); got != nil {
+				t.Errorf("Get() = %q, want nil once expired", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
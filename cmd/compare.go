@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xortim/peruse/k8sclient"
+	"go.uber.org/zap"
+)
+
+func newCompareCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compares the images of the same workloads across clusters",
+		Long: `Lines up the same workload in each configured cluster, in the order the clusters are configured,
+and shows their images side by side. Clusters running other images than the cluster before them are marked as drift.`,
+		RunE: compareRun,
+	}
+
+	cmd.Flags().String("match-label", "", "Match workloads across clusters by the value of this label instead of by namespace/name, e.g. app.kubernetes.io/name")
+	viper.BindPFlag("matchLabel", cmd.Flags().Lookup("match-label"))
+
+	return cmd
+}
+
+func compareRun(cmd *cobra.Command, args []string) error {
+	zap.S().Debugf("Compare run")
+	clusters, err := newClusters()
+	if err != nil {
+		return err
+	}
+	if len(clusters) < 2 {
+		return fmt.Errorf("compare needs at least two clusters, configure clusters or pass --contexts")
+	}
+
+	filter, err := newFilter()
+	if err != nil {
+		return err
+	}
+	result, err := clustersDeploymentIngressPaths(clusters, filter)
+	if err != nil {
+		return err
+	}
+	newComparison(clusters, result, viper.GetString("matchLabel")).FPrintTable(os.Stdout)
+	printWarnings(os.Stderr, result.Warnings)
+	return nil
+}
+
+// newComparison compares the workloads of the clusters that could be read, matching them by the label when it is set
+func newComparison(clusters []k8sclient.Cluster, result k8sclient.Result, label string) k8sclient.Comparison {
+	failed := map[string]bool{}
+	for _, cluster := range result.FailedClusters() {
+		failed[cluster] = true
+	}
+	// a cluster that could not be read would look like it runs none of the workloads
+	names := []string{}
+	for _, cluster := range clusters {
+		if !failed[cluster.Name] {
+			names = append(names, cluster.Name)
+		}
+	}
+
+	key := k8sclient.NamespaceNameKey
+	if len(label) != 0 {
+		key = k8sclient.LabelKey(label)
+	}
+	return k8sclient.NewComparison(names, result.Paths, key)
+}
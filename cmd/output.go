@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/xortim/peruse"
+	"github.com/xortim/peruse/k8sclient"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputJSONL    = "jsonl"
+	outputCSV      = "csv"
+	outputMarkdown = "markdown"
+	outputWide     = "wide"
+)
+
+var (
+	// outputFormats are the values of --output, the table is printed when it is empty
+	outputFormats = []string{outputJSON, outputYAML, outputJSONL, outputCSV, outputMarkdown, outputWide}
+
+	// outputColumns are the columns of the csv, markdown and wide outputs
+	outputColumns = []string{"Cluster", "Namespace", "Kind", "Name", "Ready", "Images", "Pods", "Services", "Routes", "URLs"}
+)
+
+// validOutput returns an error when the format is not one of outputFormats
+func validOutput(format string) error {
+	if len(format) == 0 {
+		return nil
+	}
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, expected one of %v", format, outputFormats)
+}
+
+// writeOutput writes the paths of the result in the format. Every format but the table renders the Topology of the result,
+// json and yaml along with its warnings.
+func writeOutput(w io.Writer, format string, result k8sclient.Result) error {
+	if err := validOutput(format); err != nil {
+		return err
+	}
+	if len(format) == 0 {
+		result.Paths.FPrintTable(w)
+		return nil
+	}
+
+	topology := peruse.NewTopology(result)
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(topology)
+	case outputYAML:
+		out, err := yaml.Marshal(topology)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case outputJSONL:
+		enc := json.NewEncoder(w)
+		for _, workload := range topology.Workloads {
+			if err := enc.Encode(peruse.WorkloadRecord{APIVersion: topology.APIVersion, Workload: workload}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(outputColumns)
+		for _, workload := range topology.Workloads {
+			cw.Write(workloadRow(workload, " "))
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	// markdown cells cannot span lines
+	separator := "\n"
+	if format == outputMarkdown {
+		separator = "<br>"
+	}
+	t := table.NewWriter()
+	header := table.Row{}
+	for _, column := range outputColumns {
+		header = append(header, column)
+	}
+	t.AppendHeader(header)
+	for _, workload := range topology.Workloads {
+		row := table.Row{}
+		for _, cell := range workloadRow(workload, separator) {
+			row = append(row, cell)
+		}
+		t.AppendRow(row)
+	}
+	if format == outputMarkdown {
+		_, err := fmt.Fprintln(w, t.RenderMarkdown())
+		return err
+	}
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleLight)
+	t.Render()
+	return nil
+}
+
+// workloadRow returns the outputColumns of the workload, the values of a cell joined by the separator
+func workloadRow(w peruse.Workload, separator string) []string {
+	pods := []string{}
+	for _, p := range w.Pods {
+		pods = append(pods, p.Name)
+	}
+	services := []string{}
+	for _, s := range w.Services {
+		services = append(services, s.Name)
+	}
+	routes := []string{}
+	urls := []string{}
+	for _, r := range w.Routes {
+		// resolvers may split one object into several routes, only name it once
+		if route := r.Kind + "/" + r.Name; len(routes) == 0 || routes[len(routes)-1] != route {
+			routes = append(routes, route)
+		}
+		urls = append(urls, r.URLs...)
+	}
+	return []string{
+		w.Cluster,
+		w.Namespace,
+		w.Kind,
+		w.Name,
+		fmt.Sprintf("%d/%d", w.Replicas.Ready, w.Replicas.Desired),
+		strings.Join(w.Images, separator),
+		strings.Join(pods, separator),
+		strings.Join(services, separator),
+		strings.Join(routes, separator),
+		strings.Join(urls, separator),
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xortim/peruse"
+	"github.com/xortim/peruse/k8sclient"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWriteOutput(t *testing.T) {
+	web := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web"}}
+	web.Spec.Template.Spec.Containers = []apiv1.Container{{Name: "web", Image: "shop/web:1.4.2"}, {Name: "proxy", Image: "envoy:1.13"}}
+	route := k8sclient.Route{Kind: "Ingress", Namespace: "shop", Name: "web", URLs: []url.URL{{Scheme: "https", Host: "shop.example.com", Path: "/"}}}
+	result := k8sclient.NewResult()
+	result.Paths = k8sclient.DeploymentIngressPaths{{Cluster: "staging", Workload: k8sclient.DeploymentWorkload{Deployment: web}, Routes: []k8sclient.Route{route, route}}}
+	result.Warnings = []k8sclient.Warning{{Cluster: "production", Message: "connection refused"}}
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			format: outputJSON,
+			check: func(t *testing.T, out string) {
+				topology := &peruse.Topology{}
+				if err := json.Unmarshal([]byte(out), topology); err != nil {
+					t.Fatal(err)
+				}
+				if topology.APIVersion != peruse.APIVersion || len(topology.Workloads) != 1 || len(topology.Warnings) != 1 {
+					t.Errorf("got %+v, want the versioned topology with the workload and warning", topology)
+				}
+			},
+		},
+		{
+			format: outputYAML,
+			check: func(t *testing.T, out string) {
+				topology := &peruse.Topology{}
+				if err := yaml.Unmarshal([]byte(out), topology); err != nil {
+					t.Fatal(err)
+				}
+				if topology.APIVersion != peruse.APIVersion || len(topology.Workloads) != 1 || topology.Workloads[0].Routes[0].URLs[0] != "https://shop.example.com/" {
+					t.Errorf("got %+v, want the versioned topology with the route's URL", topology)
+				}
+			},
+		},
+		{
+			format: outputJSONL,
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				record := &peruse.WorkloadRecord{}
+				if err := json.Unmarshal([]byte(lines[0]), record); err != nil {
+					t.Fatal(err)
+				}
+				if len(lines) != 1 || record.APIVersion != peruse.APIVersion || record.Name != "web" {
+					t.Errorf("got %q, want a versioned record per workload", out)
+				}
+			},
+		},
+		{
+			format: outputCSV,
+			check: func(t *testing.T, out string) {
+				records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := [][]string{
+					outputColumns,
+					{"staging", "shop", "Deployment", "web", "0/1", "shop/web:1.4.2 envoy:1.13", "", "", "Ingress/web", "https://shop.example.com/ https://shop.example.com/"},
+				}
+				if !reflect.DeepEqual(records, want) {
+					t.Errorf("got %q, want %q", records, want)
+				}
+			},
+		},
+		{
+			format: outputMarkdown,
+			check: func(t *testing.T, out string) {
+				if !strings.Contains(out, "| staging | shop | Deployment | web | 0/1 | shop/web:1.4.2<br>envoy:1.13 |") {
+					t.Errorf("got\n%s\nwant a markdown row per workload", out)
+				}
+			},
+		},
+		{
+			format: outputWide,
+			check: func(t *testing.T, out string) {
+				for _, column := range outputColumns {
+					if !strings.Contains(out, strings.ToUpper(column)) {
+						t.Errorf("got\n%s\nwant the %s column", out, column)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := writeOutput(out, tt.format, result); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, out.String())
+		})
+	}
+
+	if err := writeOutput(&bytes.Buffer{}, "xml", result); err == nil {
+		t.Error("writeOutput() should reject unknown formats")
+	}
+}
@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/xortim/peruse/conf"
 	"go.uber.org/zap"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 
 	"github.com/xortim/peruse/k8sclient"
 )
@@ -28,34 +31,184 @@ func newRootCmd() *cobra.Command {
 	cmd.AddCommand(
 		newVersionCmd(),
 		newServCmd(),
+		newCompareCmd(),
 	)
 
 	cmd.PersistentFlags().StringVarP(&cfgFile, "configfile", "c", "", "ConfigFile to use instead of the default locations")
-	cmd.PersistentFlags().String("kubeconfig", filepath.Join(conf.Home, ".kube", "config"), "Fully qualified path to the kubeconfig file")
-	cmd.PersistentFlags().StringP("namespace", "n", "", "Limit the action to this namespace")
-
-	cmd.MarkFlagRequired("kubeconfig")
+	cmd.PersistentFlags().String("kubeconfig", "", "Fully qualified path to the kubeconfig file, KUBECONFIG or ~/.kube/config when empty")
+	cmd.PersistentFlags().StringSliceP("namespace", "n", []string{}, "Limit the action to these namespaces, may be repeated")
+	cmd.PersistentFlags().StringSlice("exclude-namespace", []string{}, "Leave out these namespaces, e.g. kube-system, may be repeated")
+	cmd.PersistentFlags().String("namespace-selector", "", "Limit the action to the namespaces matching this label selector, requires listing namespaces")
+	cmd.PersistentFlags().StringP("selector", "l", "", "Only show the workloads matching this label selector, e.g. team=shop,tier!=batch")
+	cmd.PersistentFlags().StringSlice("fallback-namespaces", []string{}, "The namespaces to try when listing every namespace is forbidden and so is listing namespaces")
+	cmd.PersistentFlags().StringSlice("contexts", []string{}, "Read these contexts of the kubeconfig as separate clusters, in addition to the clusters in the config")
+	cmd.PersistentFlags().String("context", "", "The kubeconfig context to use instead of the current context")
+	cmd.PersistentFlags().String("server", "", "The address of the API server, overriding the kubeconfig")
+	cmd.PersistentFlags().String("token-file", "", "A file holding the bearer token to authenticate with, reread when it changes")
+	cmd.PersistentFlags().String("ca-file", "", "The certificate authority of --server, which is then reached without a kubeconfig")
+	cmd.PersistentFlags().String("as", "", "Impersonate this user")
+	cmd.PersistentFlags().StringSlice("as-group", []string{}, "Impersonate these groups, may be repeated")
+	cmd.PersistentFlags().String("auth-mode", k8sclient.AuthModeAuto, fmt.Sprintf("How to authenticate, one of %v. auto uses the in-cluster service account when running in a pod without --context or --server", k8sclient.AuthModes))
+	cmd.PersistentFlags().Float32("qps", 0, "Requests per second to each API server, client-go's default when 0")
+	cmd.PersistentFlags().Int("burst", 0, "Requests allowed to burst above --qps, client-go's default when 0")
+
+	cmd.Flags().StringP("output", "o", "", fmt.Sprintf("Print the workloads in this format instead of the table, one of %v", outputFormats))
+	viper.BindPFlag("output", cmd.Flags().Lookup("output"))
 
 	cmd.MarkFlagFilename("configfile")
 	cmd.MarkFlagFilename("kubeconfig")
+	cmd.MarkFlagFilename("token-file")
+	cmd.MarkFlagFilename("ca-file")
 
 	viper.BindPFlags(cmd.PersistentFlags())
+	// multi word flags are camel cased in the config like the other keys
+	viper.BindPFlag("tokenFile", cmd.PersistentFlags().Lookup("token-file"))
+	viper.BindPFlag("caFile", cmd.PersistentFlags().Lookup("ca-file"))
+	viper.BindPFlag("asGroups", cmd.PersistentFlags().Lookup("as-group"))
+	viper.BindPFlag("authMode", cmd.PersistentFlags().Lookup("auth-mode"))
+	viper.BindPFlag("fallbackNamespaces", cmd.PersistentFlags().Lookup("fallback-namespaces"))
+	viper.BindPFlag("excludeNamespaces", cmd.PersistentFlags().Lookup("exclude-namespace"))
+	viper.BindPFlag("namespaceSelector", cmd.PersistentFlags().Lookup("namespace-selector"))
 
 	return cmd
 }
 
 func rootRun(cmd *cobra.Command, args []string) error {
 	zap.S().Debugf("Root run")
-	k8s, err := k8sclient.NewClient("", viper.GetString("kubeconfig"))
-	dips, err := k8sclient.GetDeploymentIngressPaths(k8s, viper.GetString("namespace"))
+	output := viper.GetString("output")
+	if err := validOutput(output); err != nil {
+		return err
+	}
+	clusters, err := newClusters()
+	if err != nil {
+		return err
+	}
+	filter, err := newFilter()
+	if err != nil {
+		return err
+	}
+	result, err := clustersDeploymentIngressPaths(clusters, filter)
 	if err != nil {
 		return err
 	}
 
-	dips.FPrintTable(os.Stdout)
+	if err := writeOutput(os.Stdout, output, result); err != nil {
+		return err
+	}
+	printWarnings(os.Stderr, result.Warnings)
 	return nil
 }
 
+// newFilter returns the filter of --namespace, --exclude-namespace, --namespace-selector and --selector
+func newFilter() (k8sclient.Filter, error) {
+	return k8sclient.NewFilter(viper.GetStringSlice("namespace"), viper.GetStringSlice("excludeNamespaces"), viper.GetString("namespaceSelector"), viper.GetString("selector"))
+}
+
+// clustersDeploymentIngressPaths reads what the filter matches in every cluster concurrently.
+// What could not be read is named in the warnings of the result, it only fails when none of the clusters could be read.
+func clustersDeploymentIngressPaths(clusters []k8sclient.Cluster, filter k8sclient.Filter) (k8sclient.Result, error) {
+	return k8sclient.GetClustersDeploymentIngressPaths(clusters, func(cluster k8sclient.Cluster) (k8sclient.Result, error) {
+		resolvers, err := newRouteResolvers(cluster.Clientset.Discovery(), cluster.DynamicClient)
+		if err != nil {
+			return k8sclient.NewResult(), err
+		}
+		return k8sclient.GetFilteredDeploymentIngressPaths(cluster.Clientset, cluster.DynamicClient, resolvers, filter, viper.GetStringSlice("fallbackNamespaces"))
+	})
+}
+
+// printWarnings writes the warnings, telling what is missing from the output
+func printWarnings(w io.Writer, warnings []k8sclient.Warning) {
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning.String())
+	}
+}
+
+// newClusters returns the clusters declared under clusters in the config and those of --contexts.
+// Without either the single cluster of the connection flags is read and left unnamed.
+func newClusters() ([]k8sclient.Cluster, error) {
+	configs := []k8sclient.ClusterConfig{}
+	if err := viper.UnmarshalKey("clusters", &configs); err != nil {
+		return nil, err
+	}
+	for n := range configs {
+		// clusters are listed to read other clusters than the one peruse runs in
+		if len(configs[n].AuthMode) == 0 {
+			configs[n].AuthMode = k8sclient.AuthModeKubeconfig
+		}
+	}
+	for _, context := range viper.GetStringSlice("contexts") {
+		configs = append(configs, k8sclient.ClusterConfig{Kubeconfig: viper.GetString("kubeconfig"), Context: context, AuthMode: k8sclient.AuthModeKubeconfig})
+	}
+
+	if len(configs) == 0 {
+		cluster, err := k8sclient.NewCluster(withClientFlags(flagsClusterConfig()))
+		if err != nil {
+			zap.S().Error("could not authenticate to cluster\n")
+			return nil, err
+		}
+		cluster.Name = ""
+		return []k8sclient.Cluster{cluster}, nil
+	}
+
+	clusters := []k8sclient.Cluster{}
+	names := map[string]bool{}
+	for _, config := range configs {
+		cluster, err := k8sclient.NewCluster(withClientFlags(config))
+		if err != nil {
+			return nil, err
+		}
+		if names[cluster.Name] {
+			return nil, fmt.Errorf("cluster %q is configured more than once", cluster.Name)
+		}
+		names[cluster.Name] = true
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// flagsClusterConfig returns the config of the single cluster of the connection flags.
+// The kubeconfig is left empty unless it is set, so --server and --token-file reach the server without one.
+func flagsClusterConfig() k8sclient.ClusterConfig {
+	return k8sclient.ClusterConfig{
+		Kubeconfig: viper.GetString("kubeconfig"),
+		Context:    viper.GetString("context"),
+		Server:     viper.GetString("server"),
+		TokenFile:  viper.GetString("tokenFile"),
+		CAFile:     viper.GetString("caFile"),
+		AuthMode:   viper.GetString("authMode"),
+	}
+}
+
+// withClientFlags applies --as, --as-group, --qps and --burst to the config where it does not set them
+func withClientFlags(config k8sclient.ClusterConfig) k8sclient.ClusterConfig {
+	if len(config.As) == 0 {
+		config.As = viper.GetString("as")
+	}
+	if len(config.AsGroups) == 0 {
+		config.AsGroups = viper.GetStringSlice("asGroups")
+	}
+	if config.QPS == 0 {
+		config.QPS = float32(viper.GetFloat64("qps"))
+	}
+	if config.Burst == 0 {
+		config.Burst = viper.GetInt("burst")
+	}
+	return config
+}
+
+// newRouteResolvers returns the built in route resolvers along with those declared under routeMappings in the config
+func newRouteResolvers(client discovery.DiscoveryInterface, dyn dynamic.Interface) ([]k8sclient.RouteResolver, error) {
+	mappings := []k8sclient.RouteMapping{}
+	if err := viper.UnmarshalKey("routeMappings", &mappings); err != nil {
+		return nil, err
+	}
+	custom, err := k8sclient.CustomRouteResolvers(dyn, mappings)
+	if err != nil {
+		return nil, err
+	}
+	return append(k8sclient.APIRouteResolvers(client, dyn), custom...), nil
+}
+
 func initConfig() {
 	// If a config file is found, read it in.
 	if cfgFile != "" {
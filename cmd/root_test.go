@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/xortim/peruse/k8sclient"
+)
+
+func TestFlagsClusterConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want k8sclient.ClusterConfig
+	}{
+		{
+			name: "No flags - the kubeconfig is left to the default loading rules",
+			want: k8sclient.ClusterConfig{AuthMode: k8sclient.AuthModeAuto},
+		},
+		{
+			name: "Server - reached with the token and certificate authority without a kubeconfig",
+			args: []string{"--server", "https://10.0.0.1:6443", "--token-file", "/var/run/token", "--ca-file", "/var/run/ca.crt"},
+			want: k8sclient.ClusterConfig{Server: "https://10.0.0.1:6443", TokenFile: "/var/run/token", CAFile: "/var/run/ca.crt", AuthMode: k8sclient.AuthModeAuto},
+		},
+		{
+			name: "Kubeconfig - only used when set",
+			args: []string{"--kubeconfig", "/etc/peruse/kubeconfig", "--context", "staging"},
+			want: k8sclient.ClusterConfig{Kubeconfig: "/etc/peruse/kubeconfig", Context: "staging", AuthMode: k8sclient.AuthModeAuto},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer viper.Reset()
+			cmd := newRootCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := flagsClusterConfig(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flagsClusterConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
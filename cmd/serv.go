@@ -1,21 +1,73 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"html"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/xortim/peruse"
 	"github.com/xortim/peruse/cache"
 	"github.com/xortim/peruse/k8sclient"
 	"go.uber.org/zap"
 )
 
-var cacheStorage cache.Store
+var (
+	cacheStorage cache.Store
+
+	// clusterModels are kept up to date by watches and answer every request
+	clusters      []k8sclient.Cluster
+	clusterModels map[string]*clusterModel
+)
+
+// clusterModel is the models of a cluster and the route resolvers reading from them.
+// A cluster that may not be read cluster wide has a model per namespace it may read.
+type clusterModel struct {
+	namespaces []namespaceModel
+	// skipped are the namespaces that may not be read
+	skipped []string
+	// err is why the namespaces of the cluster could not be found, the cluster is then named in the warnings of every request
+	err error
+}
+
+type namespaceModel struct {
+	namespace string
+	model     *k8sclient.Model
+	resolvers []k8sclient.RouteResolver
+}
+
+// deploymentIngressPaths reads every model of the cluster, a namespace whose model cannot be read is named in the warnings.
+// It only fails when none of them can be read.
+func (m *clusterModel) deploymentIngressPaths() (k8sclient.Result, error) {
+	if m.err != nil {
+		return k8sclient.NewResult(), m.err
+	}
+	models := map[string]namespaceModel{}
+	namespaces := []string{}
+	for _, n := range m.namespaces {
+		models[n.namespace] = n
+		namespaces = append(namespaces, n.namespace)
+	}
+	result, err := k8sclient.ReadNamespaces(context.Background(), namespaces, func(namespace string) (k8sclient.Result, error) {
+		return models[namespace].model.DeploymentIngressPaths(models[namespace].resolvers)
+	})
+	if err != nil {
+		return result, err
+	}
+	for _, namespace := range m.skipped {
+		result.Warnings = append(result.Warnings, k8sclient.Warning{Namespace: namespace, Message: k8sclient.SkippedNamespaceMessage})
+	}
+	return result, nil
+}
 
 func newServCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -24,6 +76,10 @@ func newServCmd() *cobra.Command {
 		Long:  `Serves an HTML table`,
 		RunE:  servRun,
 	}
+
+	cmd.Flags().Duration("resync", 10*time.Minute, "How often the informers relist every object, watches keep the model current in between")
+	viper.BindPFlag("resync", cmd.Flags().Lookup("resync"))
+
 	return cmd
 }
 
@@ -31,6 +87,9 @@ func cached(duration string, handler func(w http.ResponseWriter, r *http.Request
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		content := cacheStorage.Get(r.RequestURI)
 		if content != nil {
+			if contentType := cacheStorage.Get(r.RequestURI + "#content-type"); len(contentType) != 0 {
+				w.Header().Set("Content-Type", string(contentType))
+			}
 			w.Write(content)
 		} else {
 			c := httptest.NewRecorder()
@@ -43,8 +102,10 @@ func cached(duration string, handler func(w http.ResponseWriter, r *http.Request
 			w.WriteHeader(c.Code)
 			content := c.Body.Bytes()
 
-			if d, err := time.ParseDuration(duration); err == nil {
+			// failures are not cached, the next request retries
+			if d, err := time.ParseDuration(duration); err == nil && c.Code == http.StatusOK {
 				cacheStorage.Set(r.RequestURI, content, d)
+				cacheStorage.Set(r.RequestURI+"#content-type", []byte(c.HeaderMap.Get("Content-Type")), d)
 			}
 
 			w.Write(content)
@@ -56,8 +117,46 @@ func cached(duration string, handler func(w http.ResponseWriter, r *http.Request
 func servRun(cmd *cobra.Command, arts []string) error {
 	cacheStorage = cache.NewStorage()
 
+	var err error
+	clusters, err = newClusters()
+	if err != nil {
+		return err
+	}
+
+	filter, err := newFilter()
+	if err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// clusters are set up concurrently so an unreachable one only delays its own model
+	models := make([]*clusterModel, len(clusters))
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for n := range clusters {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			models[n], errs[n] = newClusterModel(clusters[n], filter, stopCh)
+		}(n)
+	}
+	wg.Wait()
+
+	clusterModels = map[string]*clusterModel{}
+	for n, cluster := range clusters {
+		if errs[n] != nil {
+			return errs[n]
+		}
+		clusterModels[cluster.Name] = models[n]
+	}
+
 	r := mux.NewRouter()
-	r.Handle("/", cached("1h", HomeHandler))
+	// the model is always current, the page is only cached briefly to absorb bursts of requests
+	r.Handle("/", cached("10s", HomeHandler))
+	r.Handle("/compare", cached("10s", CompareHandler))
+	r.Handle("/api/topology", cached("10s", TopologyHandler))
 	r.HandleFunc("/healthz", HealthHandler)
 	http.Handle("/", r)
 	srv := &http.Server{
@@ -69,6 +168,67 @@ func servRun(cmd *cobra.Command, arts []string) error {
 	return srv.ListenAndServe()
 }
 
+// newClusterModel starts the models of the namespaces of the filter and waits a bounded time for them to sync.
+// The namespace and workload selectors are applied to every request instead, so namespaces labelled later are shown.
+// A cluster whose namespaces cannot be found has no models, its error is reported by every request while the other clusters are served.
+func newClusterModel(cluster k8sclient.Cluster, filter k8sclient.Filter, stopCh <-chan struct{}) (*clusterModel, error) {
+	m := &clusterModel{skipped: []string{}}
+	watched := k8sclient.Filter{Namespaces: filter.Namespaces, ExcludeNamespaces: filter.ExcludeNamespaces}
+	namespaces, err := watched.ListNamespaces(cluster.Clientset)
+	if err != nil {
+		zap.S().Warnf("could not find the namespaces of cluster %q: %s", cluster.Name, err.Error())
+		m.err = err
+		return m, nil
+	}
+	if len(namespaces) == 1 && len(namespaces[0]) == 0 {
+		// watching cluster wide without permission would fail forever, an unreachable cluster is watched cluster wide until it answers
+		if allowed, err := k8sclient.CanListNamespace(cluster.Clientset, ""); err == nil && !allowed {
+			zap.S().Infof("listing every namespace of cluster %q is forbidden, watching the accessible namespaces instead", cluster.Name)
+			if namespaces, m.skipped, err = k8sclient.AccessibleNamespaces(cluster.Clientset, viper.GetStringSlice("fallbackNamespaces")); err != nil {
+				zap.S().Warnf("could not find the accessible namespaces of cluster %q: %s", cluster.Name, err.Error())
+				m.err = err
+				return m, nil
+			}
+			if len(m.skipped) != 0 {
+				zap.S().Warnf("cluster %q: skipping namespaces that may not be read: %v", cluster.Name, m.skipped)
+			}
+		}
+	}
+
+	// the served APIs are discovered once and shared by the models of every namespace
+	discovery := k8sclient.NewDiscoveryCache(cluster.Clientset.Discovery())
+	apis := k8sclient.DiscoverModelAPIs(cluster.Clientset, discovery)
+	for _, namespace := range namespaces {
+		model := k8sclient.NewModelWithAPIs(cluster.Clientset, cluster.DynamicClient, namespace, viper.GetDuration("resync"), apis)
+		resolvers, err := newRouteResolvers(discovery, model.DynamicClient())
+		if err != nil {
+			return nil, k8sclient.ClusterError{Cluster: cluster.Name, Err: err}
+		}
+		model.Start(stopCh)
+		m.namespaces = append(m.namespaces, namespaceModel{namespace: namespace, model: model, resolvers: resolvers})
+	}
+
+	zap.S().Infof("Waiting for the informer caches of cluster %q to sync", cluster.Name)
+	// the informers of a cluster that does not sync in time keep retrying in the background
+	syncCh := make(chan struct{})
+	timer := time.AfterFunc(k8sclient.InformerSyncTimeout, func() { close(syncCh) })
+	synced := true
+	for _, n := range m.namespaces {
+		synced = n.model.WaitForCacheSync(syncCh) && synced
+	}
+	timer.Stop()
+	if !synced {
+		zap.S().Warnf("Some informer caches of cluster %q did not sync, their objects will be missing", cluster.Name)
+		return m, nil
+	}
+
+	// reading the routes once starts the informers of every route API before the first request
+	if _, err := m.deploymentIngressPaths(); err != nil {
+		zap.S().Warnf("could not read cluster %q: %s", cluster.Name, err.Error())
+	}
+	return m, nil
+}
+
 // HealthHandler serves /healthz and always returns 200
 func HealthHandler(w http.ResponseWriter, req *http.Request) {
 	zap.S().Debugf("%s - %s", req.RemoteAddr, req.RequestURI)
@@ -77,25 +237,114 @@ func HealthHandler(w http.ResponseWriter, req *http.Request) {
 	return
 }
 
-// HomeHandler serves /
+// HomeHandler serves /. What could not be read is named above the table,
+// the page is served with 503 Service Unavailable when nothing could be.
+// The namespace, exclude-namespace, namespace-selector and selector query parameters override the flags of the same name.
 func HomeHandler(w http.ResponseWriter, req *http.Request) {
-	k8s, err := k8sclient.NewClient("", viper.GetString("kubeconfig"))
+	zap.S().Debugf("Home Handler")
+	filter, err := requestFilter(req)
 	if err != nil {
-		zap.S().Errorf("Unable to authenticate: %s\n", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`500 - unable to authenticate\n`))
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	result, err := modelsDeploymentIngressPaths(filter)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writePage(w, "Peruse Workloads", result.Warnings, "")
+		return
+	}
+	t := result.Paths.NewTable()
+	t.SetHTMLCSSClass("table table-hover table-sm")
+	writePage(w, "Peruse Workloads", result.Warnings, t.RenderHTML())
+}
 
-	zap.S().Debugf("Home Handler")
-	dips, err := k8sclient.GetDeploymentIngressPaths(k8s, viper.GetString("namespace"))
+// CompareHandler serves /compare, the images of the same workloads side by side in each cluster.
+// The label query parameter matches workloads by that label instead of by namespace/name, the filter query parameters are those of /.
+func CompareHandler(w http.ResponseWriter, req *http.Request) {
+	zap.S().Debugf("Compare Handler")
+	filter, err := requestFilter(req)
 	if err != nil {
-		w.WriteHeader(http.StatusNoContent)
-		w.Write([]byte(err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	t := dips.NewTable()
+	result, err := modelsDeploymentIngressPaths(filter)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writePage(w, "Peruse Comparison", result.Warnings, "")
+		return
+	}
+	label := viper.GetString("matchLabel")
+	if l := req.URL.Query().Get("label"); len(l) != 0 {
+		label = l
+	}
+	t := newComparison(clusters, result, label).NewTable()
 	t.SetHTMLCSSClass("table table-hover table-sm")
+	writePage(w, "Peruse Comparison", result.Warnings, t.RenderHTML())
+}
+
+// TopologyHandler serves /api/topology, the workloads as JSON along with warnings naming what could not be read.
+// It returns 503 Service Unavailable when nothing could be read. The filter query parameters are those of /.
+func TopologyHandler(w http.ResponseWriter, req *http.Request) {
+	zap.S().Debugf("Topology Handler")
+	filter, err := requestFilter(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := modelsDeploymentIngressPaths(filter)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(peruse.NewTopology(result)); err != nil {
+		zap.S().Errorf("could not encode the topology: %s", err.Error())
+	}
+}
+
+// requestFilter returns the filter of the flags, each query parameter replacing the flag of the same name
+func requestFilter(req *http.Request) (k8sclient.Filter, error) {
+	query := req.URL.Query()
+	list := func(param, key string) []string {
+		values, ok := query[param]
+		if !ok {
+			return viper.GetStringSlice(key)
+		}
+		list := []string{}
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); len(item) != 0 {
+					list = append(list, item)
+				}
+			}
+		}
+		return list
+	}
+	selector := func(param, key string) string {
+		if values, ok := query[param]; ok {
+			return strings.Join(values, ",")
+		}
+		return viper.GetString(key)
+	}
+	return k8sclient.NewFilter(list("namespace", "namespace"), list("exclude-namespace", "excludeNamespaces"), selector("namespace-selector", "namespaceSelector"), selector("selector", "selector"))
+}
+
+// modelsDeploymentIngressPaths reads the models of every cluster and keeps what the filter matches
+func modelsDeploymentIngressPaths(filter k8sclient.Filter) (k8sclient.Result, error) {
+	return k8sclient.GetClustersDeploymentIngressPaths(clusters, func(cluster k8sclient.Cluster) (k8sclient.Result, error) {
+		result, err := clusterModels[cluster.Name].deploymentIngressPaths()
+		if err != nil {
+			return result, err
+		}
+		namespaces, err := filter.ListNamespaces(cluster.Clientset)
+		if err != nil {
+			return k8sclient.NewResult(), err
+		}
+		return filter.Apply(result, namespaces), nil
+	})
+}
+
+// writePage writes an HTML page with a banner for each warning above the table
+func writePage(w http.ResponseWriter, title string, warnings []k8sclient.Warning, tableHTML string) {
 	w.Write([]byte(`
 	<!doctype html>
 	<html lang="en">
@@ -107,7 +356,7 @@ func HomeHandler(w http.ResponseWriter, req *http.Request) {
 			<!-- Bootstrap CSS -->
 			<link rel="stylesheet" href="https://stackpath.bootstrapcdn.com/bootstrap/4.4.1/css/bootstrap.min.css" integrity="sha384-Vkoo8x4CGsO3+Hhxv8T/Q5PaXtkKtu6ug5TOeNV6gBiFeWPGFN9MuhOf23Q9Ifjh" crossorigin="anonymous">
 	
-			<title>Peruse Deployments</title>
+			<title>` + title + `</title>
 		</head>
 		<body>
 		<script src="https://code.jquery.com/jquery-3.4.1.slim.min.js" integrity="sha384-J6qa4849blE2+poT4WnyKhv5vZF5SrPo0iEjwBvKU7imGFAV0wwj1yYfoRSJoZ+n" crossorigin="anonymous"></script>
@@ -115,11 +364,13 @@ func HomeHandler(w http.ResponseWriter, req *http.Request) {
     <script src="https://stackpath.bootstrapcdn.com/bootstrap/4.4.1/js/bootstrap.min.js" integrity="sha384-wfSDF2E50Y2D1uUdj0O3uMBJnjuUD4Ih7YwaYd1iqfktj0Uod8GCExl3Og8ifwB6" crossorigin="anonymous"></script>
 	`))
 
-	w.Write([]byte(t.RenderHTML()))
+	for _, warning := range warnings {
+		w.Write([]byte(`<div class="alert alert-warning" role="alert">` + html.EscapeString(warning.String()) + `</div>`))
+	}
+	w.Write([]byte(tableHTML))
 
 	w.Write([]byte(`
 		</body>
 	</html>
 	`))
-	return
 }
@@ -1,9 +1,26 @@
 package cmd
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/xortim/peruse"
+	"github.com/xortim/peruse/k8sclient"
+	v1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -19,3 +36,136 @@ func TestHealthHandler(t *testing.T) {
 		t.Fatalf("wrong status code: got %q want %q", resp.Status, http.StatusOK)
 	}
 }
+
+func TestHandlersUnavailable(t *testing.T) {
+	// the model is never started, as if the cluster could not be reached
+	clientset := fake.NewSimpleClientset()
+	model := k8sclient.NewModel(clientset, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), "shop", time.Minute)
+	clusters = []k8sclient.Cluster{{Name: "edge", Clientset: clientset}}
+	clusterModels = map[string]*clusterModel{
+		"edge": {namespaces: []namespaceModel{{namespace: "shop", model: model}}},
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	HomeHandler(w, req)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `cluster &#34;edge&#34;`) {
+		t.Errorf("HomeHandler() = %d, want %d with a banner naming the cluster:\n%s", w.Code, http.StatusServiceUnavailable, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	TopologyHandler(w, req)
+	topology := &peruse.Topology{}
+	if err := json.NewDecoder(w.Body).Decode(topology); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || len(topology.Warnings) == 0 || topology.Warnings[0].Cluster != "edge" {
+		t.Errorf("TopologyHandler() = %d with warnings %+v, want %d naming the cluster", w.Code, topology.Warnings, http.StatusServiceUnavailable)
+	}
+}
+
+// review answers every SelfSubjectAccessReview with allowed
+func review(allowed bool) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = allowed
+		return true, review, nil
+	}
+}
+
+func TestNewClusterModelUnreadableCluster(t *testing.T) {
+	deployment := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web"}}
+	edge := fake.NewSimpleClientset(deployment)
+	edge.PrependReactor("create", "selfsubjectaccessreviews", review(true))
+	// listing namespaces is forbidden and there are no fallback namespaces
+	core := fake.NewSimpleClientset()
+	core.PrependReactor("create", "selfsubjectaccessreviews", review(false))
+	core.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", nil)
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	clusters = []k8sclient.Cluster{{Name: "edge", Clientset: edge, DynamicClient: dynamicClient}, {Name: "core", Clientset: core, DynamicClient: dynamicClient}}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	clusterModels = map[string]*clusterModel{}
+	for _, cluster := range clusters {
+		model, err := newClusterModel(cluster, k8sclient.Filter{}, stopCh)
+		if err != nil {
+			t.Fatalf("newClusterModel(%q) error = %v, want the cluster served with a warning", cluster.Name, err)
+		}
+		clusterModels[cluster.Name] = model
+	}
+
+	result, err := modelsDeploymentIngressPaths(k8sclient.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Paths) != 1 || result.Paths[0].Cluster != "edge" {
+		t.Errorf("modelsDeploymentIngressPaths() = %v, want the workload of edge", result.Paths)
+	}
+	if failed := result.FailedClusters(); !reflect.DeepEqual(failed, []string{"core"}) {
+		t.Errorf("modelsDeploymentIngressPaths() failed clusters = %v, want [core] in %v", failed, result.Warnings)
+	}
+}
+
+func TestNewClusterModelDiscoversOnce(t *testing.T) {
+	// requests is how many discovery and access review requests setting up the models of the namespaces takes
+	requests := func(namespaces []string) int {
+		clientset := fake.NewSimpleClientset()
+		clientset.PrependReactor("create", "selfsubjectaccessreviews", review(true))
+		cluster := k8sclient.Cluster{Name: "edge", Clientset: clientset, DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())}
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		if _, err := newClusterModel(cluster, k8sclient.Filter{Namespaces: namespaces}, stopCh); err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, action := range clientset.Actions() {
+			if action.GetResource().Resource == "resource" || action.GetResource().Resource == "selfsubjectaccessreviews" {
+				count++
+			}
+		}
+		return count
+	}
+
+	one := requests([]string{"shop"})
+	if many := requests([]string{"shop", "batch", "edge"}); many != one {
+		t.Errorf("newClusterModel() sent %d discovery requests for 3 namespaces, want the %d of a single namespace", many, one)
+	}
+}
+
+func TestRequestFilter(t *testing.T) {
+	viper.Set("excludeNamespaces", []string{"kube-system"})
+	viper.Set("selector", "team=batch")
+	defer viper.Reset()
+
+	req, err := http.NewRequest("GET", "/?namespace=shop,batch&namespace=edge&selector=team%3Dshop", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := requestFilter(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"shop", "batch", "edge"}; !reflect.DeepEqual(filter.Namespaces, want) {
+		t.Errorf("requestFilter() namespaces = %v, want %v", filter.Namespaces, want)
+	}
+	if want := []string{"kube-system"}; !reflect.DeepEqual(filter.ExcludeNamespaces, want) {
+		t.Errorf("requestFilter() excluded namespaces = %v, want the flag's %v", filter.ExcludeNamespaces, want)
+	}
+	if filter.Selector.String() != "team=shop" {
+		t.Errorf("requestFilter() selector = %q, want the query's team=shop", filter.Selector.String())
+	}
+
+	req, err = http.NewRequest("GET", "/?namespace-selector=env+in+(prod", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := requestFilter(req); err == nil {
+		t.Error("requestFilter() should reject an invalid namespace selector")
+	}
+}
@@ -1,7 +1,6 @@
 package conf
 
 import (
-	"path/filepath"
 	"strings"
 
 	"github.com/mitchellh/go-homedir"
@@ -27,6 +26,5 @@ func init() {
 	viper.SetConfigName("." + Executable)
 	viper.SetTypeByDefaultValue(true)
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.SetDefault("kubeconfig", filepath.Join(Home, ".kube", "config"))
 	viper.SetDefault("namespace", "")
 }
@@ -0,0 +1,132 @@
+package k8sclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// SkippedNamespaceMessage is the message of the warnings naming namespaces that were skipped
+	SkippedNamespaceMessage = "skipped as it may not be read"
+)
+
+var (
+	// NamespaceResources are the resources peruse must be able to list in a namespace to read it
+	NamespaceResources = []schema.GroupResource{
+		{Group: "apps", Resource: "deployments"},
+		{Group: "", Resource: "pods"},
+		{Group: "", Resource: "services"},
+	}
+)
+
+// CanList asks the API server whether the identity may list the resource in the namespace, or cluster wide when it is empty
+func CanList(clientset kubernetes.Interface, namespace string, resource schema.GroupResource) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "list",
+				Group:     resource.Group,
+				Resource:  resource.Resource,
+			},
+		},
+	}
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// CanListNamespace is whether the identity may list every one of NamespaceResources in the namespace, or cluster wide when it is empty
+func CanListNamespace(clientset kubernetes.Interface, namespace string) (bool, error) {
+	for _, resource := range NamespaceResources {
+		allowed, err := CanList(clientset, namespace, resource)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// AccessibleNamespaces splits the candidates into the namespaces the identity may read and those it may not, in the order of the candidates.
+// Without candidates every namespace is a candidate, which requires listing namespaces.
+func AccessibleNamespaces(clientset kubernetes.Interface, candidates []string) (accessible []string, skipped []string, err error) {
+	if len(candidates) == 0 {
+		namespaces, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+		if err != nil {
+			if apierrors.IsForbidden(err) {
+				return nil, nil, fmt.Errorf("listing namespaces is forbidden, set fallbackNamespaces to the namespaces to read: %s", err.Error())
+			}
+			return nil, nil, err
+		}
+		for _, ns := range namespaces.Items {
+			candidates = append(candidates, ns.Name)
+		}
+	}
+
+	// the reviews of a namespace are sequential, those of the candidates concurrent as clusters may have hundreds of namespaces
+	allowed := make([]bool, len(candidates))
+	errs := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	for n := range candidates {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			allowed[n], errs[n] = CanListNamespace(clientset, candidates[n])
+		}(n)
+	}
+	wg.Wait()
+
+	accessible = []string{}
+	skipped = []string{}
+	for n, namespace := range candidates {
+		if errs[n] != nil {
+			return nil, nil, errs[n]
+		}
+		if allowed[n] {
+			accessible = append(accessible, namespace)
+		} else {
+			skipped = append(skipped, namespace)
+		}
+	}
+	return accessible, skipped, nil
+}
+
+// GetAccessibleDeploymentIngressPaths reads every namespace like GetDeploymentIngressPaths with an empty namespace.
+// When listing cluster wide is forbidden, e.g. the identity only has namespaced Roles, the accessible namespaces among
+// the candidates are read one by one instead and the others are named in the warnings of the result.
+// An accessible namespace that cannot be read is named in the warnings too, it only fails when none of them can be read.
+func GetAccessibleDeploymentIngressPaths(clientset kubernetes.Interface, dynamicClient dynamic.Interface, resolvers []RouteResolver, candidates []string) (Result, error) {
+	return getAccessibleDeploymentIngressPaths(context.Background(), clientset, dynamicClient, resolvers, candidates, listNodesOnce(clientset))
+}
+
+func getAccessibleDeploymentIngressPaths(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, resolvers []RouteResolver, candidates []string, listNodes func() ([]apiv1.Node, error)) (Result, error) {
+	result, err := getDeploymentIngressPaths(clientset, dynamicClient, resolvers, "", listNodes)
+	if err == nil || !apierrors.IsForbidden(err) {
+		return result, err
+	}
+	zap.S().Infof("listing every namespace is forbidden, reading the accessible namespaces instead: %s", err.Error())
+
+	accessible, skipped, err := AccessibleNamespaces(clientset, candidates)
+	if err != nil {
+		return NewResult(), err
+	}
+	result, err = ReadNamespaces(ctx, accessible, func(namespace string) (Result, error) {
+		return getDeploymentIngressPaths(clientset, dynamicClient, resolvers, namespace, listNodes)
+	})
+	for _, namespace := range skipped {
+		result.Warnings = append(result.Warnings, Warning{Namespace: namespace, Message: SkippedNamespaceMessage})
+	}
+	return result, err
+}
@@ -0,0 +1,159 @@
+package k8sclient
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// namespacedClientset returns a clientset whose identity may only list in the namespaces
+func namespacedClientset(allowed []string, objects ...runtime.Object) *fake.Clientset {
+	clientset := fake.NewSimpleClientset(objects...)
+	clientset.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		resource := action.GetResource()
+		if resource.Resource == "namespaces" || contains(allowed, action.GetNamespace()) {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: resource.Group, Resource: resource.Resource}, "", nil)
+	})
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = contains(allowed, review.Spec.ResourceAttributes.Namespace)
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestGetAccessibleDeploymentIngressPaths(t *testing.T) {
+	shop := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "shop"}}
+	system := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "kube-system", UID: "system"}}
+	namespaces := []runtime.Object{
+		&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	tests := []struct {
+		name       string
+		allowed    []string
+		candidates []string
+		workloads  []string
+		skipped    []string
+	}{
+		{
+			name:      "ClusterWide - nothing is skipped",
+			allowed:   []string{"", "kube-system", "shop"},
+			workloads: []string{"kube-system/dns", "shop/web"},
+			skipped:   []string{},
+		},
+		{
+			name:      "Namespaced - only the namespaces with a role are read",
+			allowed:   []string{"shop"},
+			workloads: []string{"shop/web"},
+			skipped:   []string{"kube-system"},
+		},
+		{
+			name:       "Candidates - the configured namespaces are tried instead of listing namespaces",
+			allowed:    []string{"shop"},
+			candidates: []string{"shop", "billing"},
+			workloads:  []string{"shop/web"},
+			skipped:    []string{"billing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := namespacedClientset(tt.allowed, append([]runtime.Object{system, shop}, namespaces...)...)
+			result, err := GetAccessibleDeploymentIngressPaths(clientset, dynamicClient, []RouteResolver{}, tt.candidates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			workloads := []string{}
+			for _, dip := range result.Paths {
+				workloads = append(workloads, NamespaceNameKey(dip.Workload))
+			}
+			skipped := []string{}
+			for _, w := range result.Warnings {
+				if w.Message == SkippedNamespaceMessage {
+					skipped = append(skipped, w.Namespace)
+				}
+			}
+			if !reflect.DeepEqual(workloads, tt.workloads) {
+				t.Errorf("GetAccessibleDeploymentIngressPaths() workloads = %v, want %v", workloads, tt.workloads)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("GetAccessibleDeploymentIngressPaths() skipped = %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestGetAccessibleDeploymentIngressPathsUnreadableNamespace(t *testing.T) {
+	shop := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "shop"}}
+	// the review allows billing but reading it fails
+	clientset := namespacedClientset([]string{"shop", "billing"}, shop)
+	clientset.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "billing" {
+			return false, nil, nil
+		}
+		return true, nil, fmt.Errorf("%s are unavailable", action.GetResource().Resource)
+	})
+
+	result, err := GetAccessibleDeploymentIngressPaths(clientset, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), []RouteResolver{}, []string{"shop", "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Paths) != 1 || NamespaceNameKey(result.Paths[0].Workload) != "shop/web" {
+		t.Errorf("GetAccessibleDeploymentIngressPaths() = %v, want shop/web", result.Paths)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Namespace != "billing" || len(result.Warnings[0].Resource) != 0 {
+		t.Errorf("GetAccessibleDeploymentIngressPaths() warnings = %v, want billing", result.Warnings)
+	}
+}
+
+func TestAccessibleNamespaces(t *testing.T) {
+	candidates := []string{}
+	allowed := []string{}
+	wantSkipped := []string{}
+	for n := 0; n < 20; n++ {
+		namespace := fmt.Sprintf("team-%02d", n)
+		candidates = append(candidates, namespace)
+		if n%3 == 0 {
+			allowed = append(allowed, namespace)
+		} else {
+			wantSkipped = append(wantSkipped, namespace)
+		}
+	}
+
+	// the namespaces are reviewed concurrently, run with -race to catch shared state
+	clientset := namespacedClientset(allowed)
+	accessible, skipped, err := AccessibleNamespaces(clientset, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(accessible, allowed) || !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("AccessibleNamespaces() = %v, %v, want %v, %v in the order of the candidates", accessible, skipped, allowed, wantSkipped)
+	}
+
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		if review.Spec.ResourceAttributes.Namespace != "team-07" {
+			return false, nil, nil
+		}
+		return true, review, fmt.Errorf("the review of team-07 timed out")
+	})
+	if _, _, err := AccessibleNamespaces(clientset, candidates); err == nil || err.Error() != "the review of team-07 timed out" {
+		t.Errorf("AccessibleNamespaces() error = %v, want the failed review", err)
+	}
+}
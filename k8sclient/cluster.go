@@ -0,0 +1,214 @@
+package k8sclient
+
+import (
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// AuthModeAuto uses the in-cluster service account when running in a pod and no context or server is set, the kubeconfig otherwise
+	AuthModeAuto = "auto"
+	// AuthModeInCluster only uses the in-cluster service account
+	AuthModeInCluster = "in-cluster"
+	// AuthModeKubeconfig only uses the kubeconfig, or the server and token file
+	AuthModeKubeconfig = "kubeconfig"
+)
+
+var (
+	// AuthModes are the ways to authenticate to a cluster
+	AuthModes = []string{AuthModeAuto, AuthModeInCluster, AuthModeKubeconfig}
+)
+
+// ClusterConfig describes how to reach a cluster.
+// A cluster is either a context of a kubeconfig file, a server and service account token without a kubeconfig,
+// or the cluster peruse runs in.
+type ClusterConfig struct {
+	// Name is shown in the Cluster column, the context, server or kubeconfig when empty
+	Name string
+	// Kubeconfig is the kubeconfig file, the default loading rules (KUBECONFIG, ~/.kube/config) are used when empty
+	Kubeconfig string
+	// Context is the kubeconfig context, the current context when empty
+	Context string
+	// Server overrides the server of the context, or is reached on its own with TokenFile or CAFile
+	Server string
+	// TokenFile is a file holding a bearer token, e.g. a service account token, it is reread when it changes
+	TokenFile string
+	// CAFile is the certificate authority of Server, setting it with Server alone reaches the server without a kubeconfig
+	CAFile string
+	// AuthMode is one of AuthModes, AuthModeAuto when empty
+	AuthMode string
+	// As and AsGroups impersonate a user and groups
+	As       string
+	AsGroups []string
+	// QPS and Burst limit the requests to the API server, client-go's defaults when 0
+	QPS   float32
+	Burst int
+}
+
+// Cluster is a named cluster and the clients to reach it
+type Cluster struct {
+	Name          string
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+}
+
+// ClusterError is the reason a cluster could not be read
+type ClusterError struct {
+	Cluster string
+	Err     error
+}
+
+func (e ClusterError) Error() string {
+	if len(e.Cluster) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("cluster %q: %s", e.Cluster, e.Err.Error())
+}
+
+// ClusterName is the name of the cluster when the config does not set one
+func (c ClusterConfig) ClusterName() string {
+	switch {
+	case len(c.Name) != 0:
+		return c.Name
+	case len(c.Context) != 0:
+		return c.Context
+	case len(c.Server) != 0:
+		return c.Server
+	}
+	return c.Kubeconfig
+}
+
+// RESTConfig returns the config of the clients of the cluster
+func (c ClusterConfig) RESTConfig() (*rest.Config, error) {
+	var config *rest.Config
+	var err error
+	switch c.AuthMode {
+	case AuthModeInCluster:
+		config, err = rest.InClusterConfig()
+	case AuthModeKubeconfig:
+		config, err = c.kubeconfigRESTConfig()
+	case "", AuthModeAuto:
+		// an explicit context or server is a request for the kubeconfig even when running in a pod
+		if len(c.Context) == 0 && len(c.Server) == 0 {
+			config, err = rest.InClusterConfig()
+		}
+		if config == nil {
+			if err != nil {
+				zap.S().Debugf("could not perform incluster config, falling back to the kubeconfig: %s", err.Error())
+			}
+			config, err = c.kubeconfigRESTConfig()
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q, expected one of %v", c.AuthMode, AuthModes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.TokenFile) != 0 {
+		config.BearerToken = ""
+		config.BearerTokenFile = c.TokenFile
+	}
+	config.Impersonate = rest.ImpersonationConfig{UserName: c.As, Groups: c.AsGroups}
+	if c.QPS != 0 {
+		config.QPS = c.QPS
+	}
+	if c.Burst != 0 {
+		config.Burst = c.Burst
+	}
+	return config, nil
+}
+
+// kubeconfigRESTConfig reads the kubeconfig, --kubeconfig or else KUBECONFIG and ~/.kube/config, with the server overridden by --server.
+// --server alone reaches the server without a kubeconfig when it is given its own token or certificate authority, or when no kubeconfig loads.
+func (c ClusterConfig) kubeconfigRESTConfig() (*rest.Config, error) {
+	serverOnly := len(c.Kubeconfig) == 0 && len(c.Context) == 0 && len(c.Server) != 0
+	if serverOnly && (len(c.TokenFile) != 0 || len(c.CAFile) != 0) {
+		return c.serverRESTConfig(), nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = c.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: c.Context}
+	overrides.ClusterInfo.Server = c.Server
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil && serverOnly {
+		zap.S().Debugf("could not load the kubeconfig, reaching %s without it: %s", c.Server, err.Error())
+		return c.serverRESTConfig(), nil
+	}
+	return config, err
+}
+
+// serverRESTConfig returns the config reaching --server with --ca-file and no kubeconfig
+func (c ClusterConfig) serverRESTConfig() *rest.Config {
+	return &rest.Config{
+		Host:            c.Server,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: c.CAFile},
+	}
+}
+
+// NewCluster builds the clients of the cluster. The cluster is not contacted, an unreachable cluster fails when it is read.
+func NewCluster(config ClusterConfig) (Cluster, error) {
+	cluster := Cluster{Name: config.ClusterName()}
+	restConfig, err := config.RESTConfig()
+	if err != nil {
+		return cluster, ClusterError{Cluster: cluster.Name, Err: err}
+	}
+	if cluster.Clientset, err = kubernetes.NewForConfig(restConfig); err != nil {
+		return cluster, ClusterError{Cluster: cluster.Name, Err: err}
+	}
+	if cluster.DynamicClient, err = dynamic.NewForConfig(restConfig); err != nil {
+		return cluster, ClusterError{Cluster: cluster.Name, Err: err}
+	}
+	return cluster, nil
+}
+
+// GetClustersDeploymentIngressPaths calls get for every cluster concurrently and returns the paths in the order of the clusters,
+// each path and warning tagged with the name of its cluster. Clusters that fail are named in the warnings,
+// it only fails when every cluster does.
+func GetClustersDeploymentIngressPaths(clusters []Cluster, get func(cluster Cluster) (Result, error)) (Result, error) {
+	results := make([]Result, len(clusters))
+	errs := make([]error, len(clusters))
+
+	var wg sync.WaitGroup
+	for n := range clusters {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			results[n], errs[n] = get(clusters[n])
+		}(n)
+	}
+	wg.Wait()
+
+	result := NewResult()
+	var firstErr error
+	for n, cluster := range clusters {
+		if errs[n] != nil {
+			zap.S().Debugf("could not read cluster %q: %s", cluster.Name, errs[n].Error())
+			result.Warnings = append(result.Warnings, Warning{Cluster: cluster.Name, Message: errs[n].Error()})
+			if firstErr == nil {
+				firstErr = ClusterError{Cluster: cluster.Name, Err: errs[n]}
+			}
+			continue
+		}
+		for _, dip := range results[n].Paths {
+			dip.Cluster = cluster.Name
+			result.Paths = append(result.Paths, dip)
+		}
+		for _, w := range results[n].Warnings {
+			w.Cluster = cluster.Name
+			result.Warnings = append(result.Warnings, w)
+		}
+	}
+	if len(clusters) != 0 && len(result.FailedClusters()) == len(clusters) {
+		return result, firstErr
+	}
+	return result, nil
+}
@@ -0,0 +1,184 @@
+package k8sclient
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// setKubeconfigEnv points KUBECONFIG at path and returns the func restoring it
+func setKubeconfigEnv(t *testing.T, path string) func() {
+	previous, ok := os.LookupEnv("KUBECONFIG")
+	if err := os.Setenv("KUBECONFIG", path); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if ok {
+			os.Setenv("KUBECONFIG", previous)
+			return
+		}
+		os.Unsetenv("KUBECONFIG")
+	}
+}
+
+func TestClusterConfigRESTConfig(t *testing.T) {
+	defer setKubeconfigEnv(t, "testdata/kubeconfig")()
+
+	tests := []struct {
+		name   string
+		config ClusterConfig
+		host   string
+		token  string
+		want   func(config *rest.Config) bool
+	}{
+		{
+			name:   "Token - a server and token file without a kubeconfig",
+			config: ClusterConfig{Server: "https://edge.example.com:6443", TokenFile: "/var/run/secrets/edge/token"},
+			host:   "https://edge.example.com:6443",
+			token:  "/var/run/secrets/edge/token",
+			want:   func(config *rest.Config) bool { return len(config.BearerToken) == 0 },
+		},
+		{
+			name:   "Certificate authority - a server and certificate authority without a kubeconfig",
+			config: ClusterConfig{Server: "https://edge.example.com:6443", CAFile: "/var/run/secrets/edge/ca.crt"},
+			host:   "https://edge.example.com:6443",
+			want: func(config *rest.Config) bool {
+				return config.TLSClientConfig.CAFile == "/var/run/secrets/edge/ca.crt" && len(config.BearerToken) == 0
+			},
+		},
+		{
+			name:   "Default kubeconfig - the server alone overrides the server of the KUBECONFIG context",
+			config: ClusterConfig{Server: "https://10.0.0.1:6443"},
+			host:   "https://10.0.0.1:6443",
+			want:   func(config *rest.Config) bool { return config.BearerToken == "kubeconfig-token" },
+		},
+		{
+			name:   "Kubeconfig - the current context",
+			config: ClusterConfig{Kubeconfig: "testdata/kubeconfig", AuthMode: AuthModeKubeconfig},
+			host:   "https://staging.example.com:6443",
+			want:   func(config *rest.Config) bool { return config.BearerToken == "kubeconfig-token" },
+		},
+		{
+			name:   "Context - an explicit context is read from the kubeconfig in auto mode",
+			config: ClusterConfig{Kubeconfig: "testdata/kubeconfig", Context: "production"},
+			host:   "https://production.example.com:6443",
+		},
+		{
+			name:   "Server - the server of the context is overridden and the token file replaces its token",
+			config: ClusterConfig{Kubeconfig: "testdata/kubeconfig", Server: "https://10.0.0.1:6443", TokenFile: "/var/run/secrets/token"},
+			host:   "https://10.0.0.1:6443",
+			token:  "/var/run/secrets/token",
+			want:   func(config *rest.Config) bool { return len(config.BearerToken) == 0 },
+		},
+		{
+			name:   "Client - impersonation and rate limits",
+			config: ClusterConfig{Kubeconfig: "testdata/kubeconfig", Context: "staging", As: "jane", AsGroups: []string{"sre"}, QPS: 50, Burst: 100},
+			host:   "https://staging.example.com:6443",
+			want: func(config *rest.Config) bool {
+				return config.Impersonate.UserName == "jane" && reflect.DeepEqual(config.Impersonate.Groups, []string{"sre"}) && config.QPS == 50 && config.Burst == 100
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.config.RESTConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Host != tt.host || config.BearerTokenFile != tt.token {
+				t.Errorf("RESTConfig() = %s with token file %q, want %s with %q", config.Host, config.BearerTokenFile, tt.host, tt.token)
+			}
+			if tt.want != nil && !tt.want(config) {
+				t.Errorf("RESTConfig() = %+v", config)
+			}
+		})
+	}
+
+	restore := setKubeconfigEnv(t, "testdata/missing-kubeconfig")
+	if config, err := (ClusterConfig{Server: "https://10.0.0.1:6443"}).RESTConfig(); err != nil || config.Host != "https://10.0.0.1:6443" {
+		t.Errorf("RESTConfig() without a kubeconfig = %+v, %v, want the server", config, err)
+	}
+	restore()
+
+	failures := []ClusterConfig{
+		{Kubeconfig: "testdata/missing-kubeconfig", Context: "staging"},
+		{Kubeconfig: "testdata/kubeconfig", Context: "staging", AuthMode: "token"},
+		// the tests do not run in a pod
+		{Kubeconfig: "testdata/kubeconfig", AuthMode: AuthModeInCluster},
+	}
+	for _, config := range failures {
+		if _, err := config.RESTConfig(); err == nil {
+			t.Errorf("%+v.RESTConfig() should fail", config)
+		}
+	}
+}
+
+func TestClusterConfigClusterName(t *testing.T) {
+	tests := []struct {
+		config ClusterConfig
+		want   string
+	}{
+		{config: ClusterConfig{Name: "prod", Context: "prod-admin"}, want: "prod"},
+		{config: ClusterConfig{Context: "prod-admin", Server: "https://prod.example.com"}, want: "prod-admin"},
+		{config: ClusterConfig{Server: "https://prod.example.com"}, want: "https://prod.example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.config.ClusterName(); got != tt.want {
+			t.Errorf("%+v.ClusterName() = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestGetClustersDeploymentIngressPaths(t *testing.T) {
+	clusters := []Cluster{{Name: "staging"}, {Name: "edge"}, {Name: "production"}}
+	result, err := GetClustersDeploymentIngressPaths(clusters, func(cluster Cluster) (Result, error) {
+		if cluster.Name == "edge" {
+			return NewResult(), errors.New("connection refused")
+		}
+		result := NewResult()
+		web := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}}
+		result.Paths = DeploymentIngressPaths{{Workload: DeploymentWorkload{web}}}
+		if cluster.Name == "production" {
+			result.Warn("shop", "routes", errors.New("ingresses are forbidden"))
+		}
+		return result, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, dip := range result.Paths {
+		got = append(got, dip.Cluster+"/"+dip.Workload.GetName())
+	}
+	if want := []string{"staging/web", "production/web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetClustersDeploymentIngressPaths() = %v, want %v in the order of the clusters", got, want)
+	}
+	warnings := []string{}
+	for _, w := range result.Warnings {
+		warnings = append(warnings, w.String())
+	}
+	want := []string{`cluster "edge": connection refused`, `cluster "production": namespace "shop": routes: ingresses are forbidden`}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("GetClustersDeploymentIngressPaths() warnings = %v, want %v", warnings, want)
+	}
+	if failed := result.FailedClusters(); !reflect.DeepEqual(failed, []string{"edge"}) {
+		t.Errorf("FailedClusters() = %v, want the edge cluster", failed)
+	}
+	if !result.Paths.Clustered() {
+		t.Error("Clustered() = false, want true for paths of named clusters")
+	}
+
+	if _, err := GetClustersDeploymentIngressPaths(clusters[1:2], func(cluster Cluster) (Result, error) {
+		return NewResult(), errors.New("connection refused")
+	}); err == nil {
+		t.Error("GetClustersDeploymentIngressPaths() should fail when every cluster does")
+	}
+}
@@ -0,0 +1,150 @@
+package k8sclient
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+)
+
+// WorkloadKey identifies the same workload in different clusters
+type WorkloadKey func(workload Workload) string
+
+// NamespaceNameKey matches workloads by namespace/name
+func NamespaceNameKey(workload Workload) string {
+	return workload.GetNamespace() + "/" + workload.GetName()
+}
+
+// LabelKey matches workloads by the value of the label, e.g. app.kubernetes.io/name.
+// Workloads without the label are matched by namespace/name.
+func LabelKey(label string) WorkloadKey {
+	return func(workload Workload) string {
+		if value, ok := workload.GetLabels()[label]; ok {
+			return value
+		}
+		return NamespaceNameKey(workload)
+	}
+}
+
+// WorkloadComparison is the same workload in each cluster it runs in
+type WorkloadComparison struct {
+	Key string
+	// Workloads are keyed by cluster name, a label key may match several workloads in one cluster
+	Workloads map[string][]Workload
+}
+
+// Images returns the container images of the workload in the cluster, nil when it does not run there
+func (c WorkloadComparison) Images(cluster string) []string {
+	workloads, ok := c.Workloads[cluster]
+	if !ok {
+		return nil
+	}
+	images := []string{}
+	for _, w := range workloads {
+		for _, container := range w.PodTemplate().Spec.Containers {
+			images = append(images, container.Image)
+		}
+	}
+	return images
+}
+
+// Drift returns the clusters running other images than the cluster before them, in the order of the clusters.
+// Clusters that do not run the workload are skipped.
+func (c WorkloadComparison) Drift(clusters []string) []string {
+	drift := []string{}
+	var previous []string
+	for _, cluster := range clusters {
+		images := c.Images(cluster)
+		if images == nil {
+			continue
+		}
+		if previous != nil && !reflect.DeepEqual(previous, images) {
+			drift = append(drift, cluster)
+		}
+		previous = images
+	}
+	return drift
+}
+
+// Comparison lines up the workloads of several clusters
+type Comparison struct {
+	// Clusters are in promotion order, e.g. dev, staging then prod
+	Clusters  []string
+	Workloads []WorkloadComparison
+}
+
+// NewComparison groups the workloads of the paths by key, each path's Cluster naming its cluster.
+// External workloads have no images and are left out.
+func NewComparison(clusters []string, dips DeploymentIngressPaths, key WorkloadKey) Comparison {
+	comparisons := map[string]*WorkloadComparison{}
+	for _, dip := range dips {
+		if dip.Workload.Kind() == KindExternal {
+			continue
+		}
+		k := key(dip.Workload)
+		c, ok := comparisons[k]
+		if !ok {
+			c = &WorkloadComparison{Key: k, Workloads: map[string][]Workload{}}
+			comparisons[k] = c
+		}
+		c.Workloads[dip.Cluster] = append(c.Workloads[dip.Cluster], dip.Workload)
+	}
+
+	comparison := Comparison{Clusters: clusters, Workloads: []WorkloadComparison{}}
+	for _, c := range comparisons {
+		comparison.Workloads = append(comparison.Workloads, *c)
+	}
+	sort.Slice(comparison.Workloads, func(i, j int) bool { return comparison.Workloads[i].Key < comparison.Workloads[j].Key })
+	return comparison
+}
+
+// NewTable creates a table with the images of each workload side by side, one column per cluster
+func (c Comparison) NewTable() table.Writer {
+	t := table.NewWriter()
+	header := table.Row{"Workload"}
+	for _, cluster := range c.Clusters {
+		header = append(header, cluster)
+	}
+	t.AppendHeader(append(header, "Drift"))
+
+	for _, w := range c.Workloads {
+		row := table.Row{w.Key}
+		drift := w.Drift(c.Clusters)
+		drifted := map[string]bool{}
+		for _, cluster := range drift {
+			drifted[cluster] = true
+		}
+		previous := ""
+		for _, cluster := range c.Clusters {
+			images := w.Images(cluster)
+			if images == nil {
+				row = append(row, "-")
+				continue
+			}
+			cell := strings.Join(images, "\n")
+			if drifted[cluster] {
+				cell += fmt.Sprintf("\n! differs from %s", previous)
+			}
+			row = append(row, cell)
+			previous = cluster
+		}
+		if len(drift) != 0 {
+			row = append(row, "drift")
+		} else {
+			row = append(row, "")
+		}
+		t.AppendRow(row)
+	}
+	return t
+}
+
+// FPrintTable prints the comparison as an ascii table
+func (c Comparison) FPrintTable(w io.Writer) {
+	t := c.NewTable()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleLight)
+	t.Render()
+}
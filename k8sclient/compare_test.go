@@ -0,0 +1,81 @@
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func compareDeployment(cluster, namespace, name, app string, images ...string) DeploymentIngressPath {
+	d := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}}}
+	for _, image := range images {
+		d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, apiv1.Container{Image: image})
+	}
+	return DeploymentIngressPath{Cluster: cluster, Workload: DeploymentWorkload{d}}
+}
+
+func TestNewComparison(t *testing.T) {
+	clusters := []string{"dev", "staging", "prod"}
+	dips := DeploymentIngressPaths{
+		compareDeployment("dev", "shop", "web", "web", "shop/web:1.5.0"),
+		compareDeployment("dev", "shop", "cart", "cart", "shop/cart:2.0.0"),
+		compareDeployment("staging", "shop", "web", "web", "shop/web:1.5.0"),
+		compareDeployment("staging", "shop", "cart", "cart", "shop/cart:2.0.0"),
+		compareDeployment("prod", "shop-prod", "web", "web", "shop/web:1.4.2"),
+		compareDeployment("prod", "shop", "cart", "cart", "shop/cart:2.0.0"),
+	}
+
+	tests := []struct {
+		name  string
+		key   WorkloadKey
+		keys  []string
+		drift map[string][]string
+		// prod are the images of each key in prod
+		prod map[string][]string
+	}{
+		{
+			name: "NamespaceName - prod web lives in another namespace",
+			key:  NamespaceNameKey,
+			keys: []string{"shop-prod/web", "shop/cart", "shop/web"},
+			drift: map[string][]string{
+				"shop-prod/web": {},
+				"shop/cart":     {},
+				"shop/web":      {},
+			},
+			prod: map[string][]string{"shop-prod/web": {"shop/web:1.4.2"}, "shop/web": nil},
+		},
+		{
+			name: "Label - web is matched across namespaces and prod is behind",
+			key:  LabelKey("app"),
+			keys: []string{"cart", "web"},
+			drift: map[string][]string{
+				"cart": {},
+				"web":  {"prod"},
+			},
+			prod: map[string][]string{"web": {"shop/web:1.4.2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := NewComparison(clusters, dips, tt.key)
+			keys := []string{}
+			for _, w := range comparison.Workloads {
+				keys = append(keys, w.Key)
+				if drift := w.Drift(clusters); !reflect.DeepEqual(drift, tt.drift[w.Key]) {
+					t.Errorf("%s Drift() = %v, want %v", w.Key, drift, tt.drift[w.Key])
+				}
+				if images, ok := tt.prod[w.Key]; ok && !reflect.DeepEqual(w.Images("prod"), images) {
+					t.Errorf("%s Images(prod) = %v, want %v", w.Key, w.Images("prod"), images)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("NewComparison() keys = %v, want %v", keys, tt.keys)
+			}
+		})
+	}
+
+}
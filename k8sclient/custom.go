@@ -0,0 +1,246 @@
+package k8sclient
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// RouteMapping declares how to read routes out of a custom resource.
+// Every field other than the GroupVersionResource and Kind is a JSONPath expression evaluated against each object.
+type RouteMapping struct {
+	Group    string
+	Version  string
+	Resource string
+	// Kind is reported on the resulting routes, it defaults to Resource
+	Kind string
+
+	// Hosts and Paths are combined into one URL per host and path
+	Hosts string
+	Paths string
+	// TLS switches the URLs to https when it yields any value other than empty or false
+	TLS string
+	// ServiceName and ServicePort are paired within each element of the last list both expressions go through,
+	// e.g. `.spec.routes[*]`, a missing port matches any port of the service
+	ServiceName string
+	ServicePort string
+	// Class is the controller responsible for the route
+	Class string
+}
+
+// GroupVersionResource returns the resource the mapping lists
+func (m RouteMapping) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: m.Group, Version: m.Version, Resource: m.Resource}
+}
+
+// CustomRouteResolver returns a RouteResolver that lists the mapping's resource and reads routes with its expressions
+func CustomRouteResolver(client dynamic.Interface, mapping RouteMapping) (RouteResolver, error) {
+	if len(mapping.Version) == 0 || len(mapping.Resource) == 0 {
+		return nil, fmt.Errorf("route mapping %q requires a version and resource", mapping.GroupVersionResource().String())
+	}
+	if len(mapping.ServiceName) == 0 {
+		return nil, fmt.Errorf("route mapping %q requires a serviceName expression", mapping.GroupVersionResource().String())
+	}
+	kind := mapping.Kind
+	if len(kind) == 0 {
+		kind = mapping.Resource
+	}
+
+	// a parsed JSONPath keeps state while it is evaluated, so only the expressions are kept and each call parses its own
+	expressions := map[string]string{}
+	for name, expr := range map[string]string{
+		"hosts":       mapping.Hosts,
+		"paths":       mapping.Paths,
+		"tls":         mapping.TLS,
+		"serviceName": mapping.ServiceName,
+		"servicePort": mapping.ServicePort,
+		"class":       mapping.Class,
+	} {
+		if len(expr) == 0 {
+			continue
+		}
+		if _, err := parseJSONPath(name, expr); err != nil {
+			return nil, fmt.Errorf("route mapping %q has an invalid %s expression: %s", mapping.GroupVersionResource().String(), name, err.Error())
+		}
+		expressions[name] = expr
+	}
+	// names and ports are evaluated per element as AllowMissingKeys drops missing ports, which would shift the ports of the next elements
+	if parent, name, port, ok := backendExpressions(mapping.ServiceName, mapping.ServicePort); ok {
+		for name, expr := range map[string]string{"backends": parent, "backendName": name, "backendPort": port} {
+			if _, err := parseJSONPath(name, expr); err != nil {
+				return nil, fmt.Errorf("route mapping %q has an invalid serviceName or servicePort expression: %s", mapping.GroupVersionResource().String(), err.Error())
+			}
+			expressions[name] = expr
+		}
+	}
+
+	return func(namespace string) ([]Route, error) {
+		items, err := ListUnstructured(client.Resource(mapping.GroupVersionResource()).Namespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+
+		parsed := map[string]*jsonpath.JSONPath{}
+		for name, expr := range expressions {
+			if parsed[name], err = parseJSONPath(name, expr); err != nil {
+				return nil, err
+			}
+		}
+
+		routes := []Route{}
+		for _, item := range items {
+			values := func(name string) []string {
+				j, ok := parsed[name]
+				if !ok {
+					return nil
+				}
+				return jsonPathStrings(j, item.Object)
+			}
+
+			route := Route{
+				Kind:      kind,
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+				Class:     strings.Join(values("class"), ","),
+			}
+
+			scheme := "http"
+			for _, v := range values("tls") {
+				if len(v) != 0 && v != "false" {
+					scheme = "https"
+				}
+			}
+			paths := values("paths")
+			if len(paths) == 0 {
+				paths = []string{"/"}
+			}
+			for _, host := range values("hosts") {
+				for _, path := range paths {
+					route.URLs = append(route.URLs, url.URL{Scheme: scheme, Host: host, Path: path})
+				}
+			}
+
+			mismatched := false
+			if backends, ok := parsed["backends"]; ok {
+				for _, element := range jsonPathValues(backends, item.Object) {
+					names := jsonPathStrings(parsed["backendName"], element)
+					ports := jsonPathStrings(parsed["backendPort"], element)
+					mismatched = mismatched || (len(ports) != 0 && len(ports) != len(names))
+					route.Backends = append(route.Backends, pairedBackends(names, ports)...)
+				}
+			} else {
+				names := values("serviceName")
+				ports := values("servicePort")
+				mismatched = len(ports) != 0 && len(ports) != len(names)
+				route.Backends = pairedBackends(names, ports)
+			}
+			if mismatched {
+				zap.S().Warnf("%s %q: the service names and ports of the route mapping do not pair up, matching any port of the services", kind, route.Namespace+"/"+route.Name)
+			}
+			routes = append(routes, route)
+		}
+		return routes, nil
+	}, nil
+}
+
+// backendExpressions splits the serviceName and servicePort expressions after the last list they share,
+// e.g. `.spec.routes[*].upstream.service` into `.spec.routes[*]` and `.upstream.service`
+func backendExpressions(serviceName, servicePort string) (parent, name, port string, ok bool) {
+	serviceName, servicePort = strings.Trim(serviceName, "{}"), strings.Trim(servicePort, "{}")
+	n := 0
+	for n < len(serviceName) && n < len(servicePort) && serviceName[n] == servicePort[n] {
+		n++
+	}
+	i := strings.LastIndex(serviceName[:n], "[*]")
+	if i == -1 {
+		return "", "", "", false
+	}
+	i += len("[*]")
+	if len(serviceName) == i || len(servicePort) == i {
+		return "", "", "", false
+	}
+	return serviceName[:i], serviceName[i:], servicePort[i:], true
+}
+
+// pairedBackends pairs the service names and ports by position.
+// Ports that do not pair up with the names cannot be attributed and every backend matches any port instead.
+func pairedBackends(names, ports []string) []RouteBackend {
+	if len(ports) != len(names) {
+		ports = nil
+	}
+	backends := []RouteBackend{}
+	for i, name := range names {
+		backend := RouteBackend{ServiceName: name}
+		if i < len(ports) {
+			if n, err := strconv.Atoi(ports[i]); err == nil {
+				backend.ServicePort = intstr.FromInt(n)
+			} else {
+				backend.ServicePort = intstr.FromString(ports[i])
+			}
+		}
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+// parseJSONPath parses the expression, adding the braces kubectl lets users omit
+func parseJSONPath(name, expr string) (*jsonpath.JSONPath, error) {
+	if !strings.HasPrefix(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	j := jsonpath.New(name).AllowMissingKeys(true)
+	if err := j.Parse(expr); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// CustomRouteResolvers returns a RouteResolver for each mapping
+func CustomRouteResolvers(client dynamic.Interface, mappings []RouteMapping) ([]RouteResolver, error) {
+	resolvers := []RouteResolver{}
+	for _, m := range mappings {
+		r, err := CustomRouteResolver(client, m)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, r)
+	}
+	return resolvers, nil
+}
+
+// jsonPathStrings returns every non-empty result of the expression as a string
+func jsonPathStrings(j *jsonpath.JSONPath, data interface{}) []string {
+	values := []string{}
+	for _, r := range jsonPathValues(j, data) {
+		v := fmt.Sprint(r)
+		if len(v) != 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// jsonPathValues returns every non-nil result of the expression
+func jsonPathValues(j *jsonpath.JSONPath, data interface{}) []interface{} {
+	results, err := j.FindResults(data)
+	if err != nil {
+		return nil
+	}
+	values := []interface{}{}
+	for _, result := range results {
+		for _, r := range result {
+			if !r.IsValid() || !r.CanInterface() || r.Interface() == nil {
+				continue
+			}
+			values = append(values, r.Interface())
+		}
+	}
+	return values
+}
@@ -0,0 +1,154 @@
+package k8sclient
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestCustomRouteResolver(t *testing.T) {
+	edgeRoute := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "edge.example.com/v1",
+		"kind":       "EdgeRoute",
+		"metadata":   map[string]interface{}{"name": "shop", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"domains": []interface{}{"shop.example.com"},
+			"tls":     map[string]interface{}{"enabled": true},
+			"routes": []interface{}{
+				map[string]interface{}{"prefix": "/", "upstream": map[string]interface{}{"service": "frontend", "port": int64(8080)}},
+				map[string]interface{}{"prefix": "/api", "upstream": map[string]interface{}{"service": "api", "port": "http"}},
+			},
+		},
+	}}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), edgeRoute)
+
+	resolve, err := CustomRouteResolver(client, RouteMapping{
+		Group:       "edge.example.com",
+		Version:     "v1",
+		Resource:    "edgeroutes",
+		Kind:        "EdgeRoute",
+		Hosts:       ".spec.domains[*]",
+		Paths:       ".spec.routes[*].prefix",
+		TLS:         ".spec.tls.enabled",
+		ServiceName: ".spec.routes[*].upstream.service",
+		ServicePort: ".spec.routes[*].upstream.port",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := resolve("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("got %d routes, want 1", len(routes))
+	}
+
+	urls := []string{}
+	for _, u := range routes[0].URLs {
+		urls = append(urls, u.String())
+	}
+	if want := []string{"https://shop.example.com/", "https://shop.example.com/api"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("URLs = %v, want %v", urls, want)
+	}
+	if len(routes[0].Backends) != 2 || routes[0].Backends[0].ServicePort.IntValue() != 8080 || routes[0].Backends[1].ServicePort.String() != "http" {
+		t.Errorf("Backends = %+v, want frontend:8080 and api:http", routes[0].Backends)
+	}
+	// serv answers concurrent requests with the same resolvers, run with -race to catch shared state
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if routes, err := resolve("default"); err != nil || len(routes) != 1 || len(routes[0].URLs) != 2 {
+				t.Errorf("concurrent resolve() = %+v, %v", routes, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCustomRouteResolverBackends(t *testing.T) {
+	gv := schema.GroupVersion{Group: "edge.example.com", Version: "v1"}
+	upstream := func(service string, port interface{}) map[string]interface{} {
+		u := map[string]interface{}{"service": service}
+		if port != nil {
+			u["port"] = port
+		}
+		return map[string]interface{}{"prefix": "/" + service, "upstream": u}
+	}
+	edgeRoute := map[string]interface{}{
+		"apiVersion": gv.String(),
+		"kind":       "EdgeRoute",
+		"metadata":   map[string]interface{}{"name": "shop", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"routes":   []interface{}{upstream("a", int64(80)), upstream("b", nil), upstream("c", int64(90))},
+			"services": []interface{}{"a", "b", "c"},
+			"ports":    []interface{}{int64(80), int64(90)},
+		},
+	}
+	client := testDynamicClient(t, gv, map[string][]map[string]interface{}{"edgeroutes": {edgeRoute}})
+
+	tests := []struct {
+		name        string
+		serviceName string
+		servicePort string
+		want        []string
+	}{
+		{
+			name:        "Elements - a route without a port matches any port and does not shift the next ports",
+			serviceName: ".spec.routes[*].upstream.service",
+			servicePort: "{.spec.routes[*].upstream.port}",
+			want:        []string{"a:80", "b:0", "c:90"},
+		},
+		{
+			name:        "No port - every service matches any port",
+			serviceName: ".spec.routes[*].upstream.service",
+			want:        []string{"a:0", "b:0", "c:0"},
+		},
+		{
+			name:        "Separate lists - ports that do not pair up with the names are ignored",
+			serviceName: ".spec.services[*]",
+			servicePort: ".spec.ports[*]",
+			want:        []string{"a:0", "b:0", "c:0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolve, err := CustomRouteResolver(client, RouteMapping{Group: gv.Group, Version: gv.Version, Resource: "edgeroutes", ServiceName: tt.serviceName, ServicePort: tt.servicePort})
+			if err != nil {
+				t.Fatal(err)
+			}
+			routes, err := resolve("default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(routes) != 1 {
+				t.Fatalf("got %d routes, want 1", len(routes))
+			}
+			got := []string{}
+			for _, b := range routes[0].Backends {
+				got = append(got, b.ServiceName+":"+b.ServicePort.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Backends = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomRouteResolverValidation(t *testing.T) {
+	if _, err := CustomRouteResolver(nil, RouteMapping{Version: "v1", Resource: "edgeroutes"}); err == nil {
+		t.Error("CustomRouteResolver() without a serviceName expression should fail")
+	}
+	if _, err := CustomRouteResolver(nil, RouteMapping{Version: "v1", Resource: "edgeroutes", ServiceName: "{.spec["}); err == nil {
+		t.Error("CustomRouteResolver() with an invalid expression should fail")
+	}
+}
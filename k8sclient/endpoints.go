@@ -0,0 +1,180 @@
+package k8sclient
+
+import (
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EndpointSliceServiceNameLabel names the service an EndpointSlice belongs to
+	EndpointSliceServiceNameLabel = "kubernetes.io/service-name"
+)
+
+var (
+	// EndpointSliceGroupVersions are the EndpointSlice group versions, in order of preference
+	EndpointSliceGroupVersions = []schema.GroupVersion{
+		{Group: "discovery.k8s.io", Version: "v1"},
+		{Group: "discovery.k8s.io", Version: "v1beta1"},
+	}
+)
+
+// EndpointAddress is a single address backing a service and whether it receives traffic
+type EndpointAddress struct {
+	IP        string
+	Hostname  string
+	NodeName  string
+	Ready     bool
+	TargetRef *apiv1.ObjectReference
+}
+
+// ServiceEndpoints maps a service's namespace/name to the addresses backing it
+type ServiceEndpoints map[string][]EndpointAddress
+
+// ServiceKey returns the key of the service in ServiceEndpoints
+func ServiceKey(service apiv1.Service) string {
+	return service.Namespace + "/" + service.Name
+}
+
+// endpointSliceObject is the union of the discovery.k8s.io/v1beta1 and v1 EndpointSlice shapes
+type endpointSliceObject struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Endpoints         []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready,omitempty"`
+		} `json:"conditions,omitempty"`
+		Hostname  *string                `json:"hostname,omitempty"`
+		NodeName  *string                `json:"nodeName,omitempty"`
+		Topology  map[string]string      `json:"topology,omitempty"`
+		TargetRef *apiv1.ObjectReference `json:"targetRef,omitempty"`
+	} `json:"endpoints"`
+}
+
+// ListServiceEndpoints returns the addresses backing each service in the namespace.
+// EndpointSlices are used when the cluster serves them, otherwise Endpoints.
+func ListServiceEndpoints(clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string) (ServiceEndpoints, error) {
+	gv, err := PreferredGroupVersion(clientset.Discovery(), EndpointSliceGroupVersions, "endpointslices")
+	if err != nil {
+		zap.S().Debugf("endpointslices are not served, falling back to endpoints: %s", err.Error())
+		endpoints := []apiv1.Endpoints{}
+		err := listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+			page, err := clientset.CoreV1().Endpoints(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, page.Items...)
+			return page, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return EndpointsServiceEndpoints(endpoints), nil
+	}
+
+	slices, err := ListUnstructured(dynamicClient.Resource(gv.WithResource("endpointslices")).Namespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+	return EndpointSliceServiceEndpoints(slices)
+}
+
+// EndpointSliceServiceEndpoints returns the addresses backing each service from discovery.k8s.io EndpointSlices
+func EndpointSliceServiceEndpoints(slices []unstructured.Unstructured) (ServiceEndpoints, error) {
+	result := ServiceEndpoints{}
+	for _, item := range slices {
+		slice := endpointSliceObject{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &slice); err != nil {
+			return nil, err
+		}
+		serviceName, ok := slice.Labels[EndpointSliceServiceNameLabel]
+		if !ok {
+			continue
+		}
+		key := slice.Namespace + "/" + serviceName
+		for _, ep := range slice.Endpoints {
+			for _, ip := range ep.Addresses {
+				// a nil ready condition is unknown, which consumers should treat as ready
+				address := EndpointAddress{IP: ip, Ready: ep.Conditions.Ready == nil || *ep.Conditions.Ready, TargetRef: ep.TargetRef}
+				if ep.Hostname != nil {
+					address.Hostname = *ep.Hostname
+				}
+				if ep.NodeName != nil {
+					address.NodeName = *ep.NodeName
+				} else {
+					address.NodeName = ep.Topology["kubernetes.io/hostname"]
+				}
+				result[key] = append(result[key], address)
+			}
+		}
+	}
+	return result, nil
+}
+
+// EndpointsServiceEndpoints returns the addresses backing each service from core/v1 Endpoints
+func EndpointsServiceEndpoints(endpoints []apiv1.Endpoints) ServiceEndpoints {
+	result := ServiceEndpoints{}
+	for _, ep := range endpoints {
+		key := ep.Namespace + "/" + ep.Name
+		for _, subset := range ep.Subsets {
+			for _, a := range subset.Addresses {
+				result[key] = append(result[key], newEndpointAddress(a, true))
+			}
+			for _, a := range subset.NotReadyAddresses {
+				result[key] = append(result[key], newEndpointAddress(a, false))
+			}
+		}
+	}
+	return result
+}
+
+func newEndpointAddress(a apiv1.EndpointAddress, ready bool) EndpointAddress {
+	address := EndpointAddress{IP: a.IP, Hostname: a.Hostname, Ready: ready, TargetRef: a.TargetRef}
+	if a.NodeName != nil {
+		address.NodeName = *a.NodeName
+	}
+	return address
+}
+
+// PodAddresses returns the addresses that target one of the pods
+func PodAddresses(addresses []EndpointAddress, pods []apiv1.Pod) []EndpointAddress {
+	result := []EndpointAddress{}
+	for _, a := range addresses {
+		if a.TargetRef == nil || a.TargetRef.Kind != KindPod {
+			continue
+		}
+		for _, p := range pods {
+			if a.TargetRef.Name == p.Name && a.TargetRef.Namespace == p.Namespace {
+				result = append(result, a)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// ExternalAddresses returns the addresses that do not target a pod, as set by manually managed Endpoints
+func ExternalAddresses(addresses []EndpointAddress) []EndpointAddress {
+	result := []EndpointAddress{}
+	for _, a := range addresses {
+		if a.TargetRef == nil || a.TargetRef.Kind != KindPod {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// PodEndpointReady returns whether the pod is a ready endpoint of any of the path's services.
+// ok is false when the pod is not an endpoint of any service.
+func (dip DeploymentIngressPath) PodEndpointReady(pod apiv1.Pod) (ready bool, ok bool) {
+	for _, a := range PodAddresses(dip.Endpoints, []apiv1.Pod{pod}) {
+		ok = true
+		ready = ready || a.Ready
+	}
+	return ready, ok
+}
@@ -0,0 +1,89 @@
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestEndpointSliceServiceEndpoints(t *testing.T) {
+	endpoint := func(ip string, conditions map[string]interface{}, extra map[string]interface{}) interface{} {
+		ep := map[string]interface{}{
+			"addresses":  []interface{}{ip},
+			"conditions": conditions,
+			"targetRef":  map[string]interface{}{"kind": KindPod, "name": "web-" + ip, "namespace": "default"},
+		}
+		for k, v := range extra {
+			ep[k] = v
+		}
+		return ep
+	}
+	slice := func(name string, labels map[string]interface{}, endpoints ...interface{}) unstructured.Unstructured {
+		metadata := map[string]interface{}{"name": name, "namespace": "default"}
+		if labels != nil {
+			metadata["labels"] = labels
+		}
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "discovery.k8s.io/v1",
+			"kind":       "EndpointSlice",
+			"metadata":   metadata,
+			"endpoints":  endpoints,
+		}}
+	}
+	web := map[string]interface{}{EndpointSliceServiceNameLabel: "web"}
+
+	got, err := EndpointSliceServiceEndpoints([]unstructured.Unstructured{
+		slice("web-a", web,
+			// v1 reports the node by name
+			endpoint("10.42.0.5", map[string]interface{}{"ready": true}, map[string]interface{}{"nodeName": "node-a", "hostname": "web-0"}),
+			endpoint("10.42.0.6", map[string]interface{}{"ready": false}, nil),
+			// a nil ready condition is unknown and treated as ready
+			endpoint("10.42.0.7", map[string]interface{}{}, nil),
+		),
+		// v1beta1 only reports the node in its topology
+		slice("web-b", web, endpoint("10.42.1.5", map[string]interface{}{"ready": true}, map[string]interface{}{"topology": map[string]interface{}{"kubernetes.io/hostname": "node-b"}})),
+		// slices without the service name label are not managed for a service
+		slice("orphan", nil, endpoint("10.42.2.5", map[string]interface{}{"ready": true}, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := func(ip string) *apiv1.ObjectReference {
+		return &apiv1.ObjectReference{Kind: KindPod, Name: "web-" + ip, Namespace: "default"}
+	}
+	want := ServiceEndpoints{
+		"default/web": {
+			{IP: "10.42.0.5", Hostname: "web-0", NodeName: "node-a", Ready: true, TargetRef: ref("10.42.0.5")},
+			{IP: "10.42.0.6", Ready: false, TargetRef: ref("10.42.0.6")},
+			{IP: "10.42.0.7", Ready: true, TargetRef: ref("10.42.0.7")},
+			{IP: "10.42.1.5", NodeName: "node-b", Ready: true, TargetRef: ref("10.42.1.5")},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EndpointSliceServiceEndpoints() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestEndpointsServiceEndpoints(t *testing.T) {
+	node := "node-a"
+	endpoints := apiv1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	endpoints.Subsets = []apiv1.EndpointSubset{{
+		Addresses:         []apiv1.EndpointAddress{{IP: "10.42.0.5", Hostname: "web-0", NodeName: &node}},
+		NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.42.0.6"}},
+	}}
+
+	got := EndpointsServiceEndpoints([]apiv1.Endpoints{endpoints})
+	want := ServiceEndpoints{
+		"default/web": {
+			{IP: "10.42.0.5", Hostname: "web-0", NodeName: "node-a", Ready: true},
+			{IP: "10.42.0.6", Ready: false},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EndpointsServiceEndpoints() =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package k8sclient
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Filter limits the namespaces that are read and the workloads that are reported
+type Filter struct {
+	// Namespaces are read one by one, every namespace is read when empty
+	Namespaces []string
+	// ExcludeNamespaces are left out, e.g. kube-system
+	ExcludeNamespaces []string
+	// NamespaceSelector matches the labels of the namespaces to read, everything when nil
+	NamespaceSelector labels.Selector
+	// Selector matches the labels of the workloads to report, everything when nil
+	Selector labels.Selector
+}
+
+// NewFilter parses the label selectors of a filter, e.g. `team=shop,tier!=batch`
+func NewFilter(namespaces, excludeNamespaces []string, namespaceSelector, selector string) (Filter, error) {
+	filter := Filter{Namespaces: namespaces, ExcludeNamespaces: excludeNamespaces}
+	var err error
+	if filter.NamespaceSelector, err = labels.Parse(namespaceSelector); err != nil {
+		return filter, fmt.Errorf("invalid namespace selector %q: %s", namespaceSelector, err.Error())
+	}
+	if filter.Selector, err = labels.Parse(selector); err != nil {
+		return filter, fmt.Errorf("invalid selector %q: %s", selector, err.Error())
+	}
+	return filter, nil
+}
+
+// ListNamespaces returns the namespaces to read, a single empty namespace when every namespace is read.
+// Namespaces are only listed when the filter has a namespace selector.
+func (f Filter) ListNamespaces(clientset kubernetes.Interface) ([]string, error) {
+	if f.NamespaceSelector == nil || f.NamespaceSelector.Empty() {
+		namespaces := []string{}
+		for _, namespace := range f.Namespaces {
+			if !f.excluded(namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		if len(f.Namespaces) == 0 {
+			namespaces = append(namespaces, "")
+		}
+		return namespaces, nil
+	}
+
+	list, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: f.NamespaceSelector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the namespaces matching %q: %s", f.NamespaceSelector.String(), err.Error())
+	}
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		if f.excluded(ns.Name) || (len(f.Namespaces) != 0 && !contains(f.Namespaces, ns.Name)) {
+			continue
+		}
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
+}
+
+// Apply keeps the paths and warnings of the namespaces, as returned by ListNamespaces, that are not excluded
+// and the workloads matching the selector
+func (f Filter) Apply(result Result, namespaces []string) Result {
+	matches := func(namespace string) bool {
+		if f.excluded(namespace) {
+			return false
+		}
+		// every namespace was read
+		if len(namespaces) == 1 && len(namespaces[0]) == 0 {
+			return true
+		}
+		return contains(namespaces, namespace)
+	}
+
+	filtered := Result{Paths: DeploymentIngressPaths{}, Warnings: []Warning{}}
+	for _, dip := range result.Paths {
+		if !matches(dip.Workload.GetNamespace()) {
+			continue
+		}
+		if f.Selector != nil && !f.Selector.Matches(labels.Set(dip.Workload.GetLabels())) {
+			continue
+		}
+		filtered.Paths = append(filtered.Paths, dip)
+	}
+	for _, w := range result.Warnings {
+		if len(w.Namespace) == 0 || matches(w.Namespace) {
+			filtered.Warnings = append(filtered.Warnings, w)
+		}
+	}
+	return filtered
+}
+
+func (f Filter) excluded(namespace string) bool {
+	return contains(f.ExcludeNamespaces, namespace)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadNamespaces calls read for every namespace and adds up the results, a namespace that cannot be read is named in the warnings.
+// It only fails when none of the namespaces can be read, or with the error of the context when it is done before the next namespace.
+func ReadNamespaces(ctx context.Context, namespaces []string, read func(namespace string) (Result, error)) (Result, error) {
+	result := NewResult()
+	var firstErr error
+	failed := 0
+	for _, namespace := range namespaces {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		namespaceResult, err := read(namespace)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			result.Warnings = append(result.Warnings, Warning{Namespace: namespace, Message: err.Error()})
+			continue
+		}
+		result.Add(namespaceResult)
+	}
+	if failed != 0 && failed == len(namespaces) {
+		return result, firstErr
+	}
+	return result, nil
+}
+
+// GetFilteredDeploymentIngressPaths reads the namespaces of the filter like GetDeploymentIngressPaths, or like
+// GetAccessibleDeploymentIngressPaths when every namespace is read, and keeps what the filter matches.
+// A namespace that cannot be read is named in the warnings, it only fails when none of them can be read.
+func GetFilteredDeploymentIngressPaths(clientset kubernetes.Interface, dynamicClient dynamic.Interface, resolvers []RouteResolver, filter Filter, fallback []string) (Result, error) {
+	return GetFilteredDeploymentIngressPathsContext(context.Background(), clientset, dynamicClient, resolvers, filter, fallback)
+}
+
+// GetFilteredDeploymentIngressPathsContext is GetFilteredDeploymentIngressPaths stopping between namespaces once the context is done
+func GetFilteredDeploymentIngressPathsContext(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, resolvers []RouteResolver, filter Filter, fallback []string) (Result, error) {
+	namespaces, err := filter.ListNamespaces(clientset)
+	if err != nil {
+		return NewResult(), err
+	}
+
+	listNodes := listNodesOnce(clientset)
+	result, err := ReadNamespaces(ctx, namespaces, func(namespace string) (Result, error) {
+		if len(namespace) == 0 {
+			return getAccessibleDeploymentIngressPaths(ctx, clientset, dynamicClient, resolvers, fallback, listNodes)
+		}
+		return getDeploymentIngressPaths(clientset, dynamicClient, resolvers, namespace, listNodes)
+	})
+	if err != nil {
+		return result, err
+	}
+	return filter.Apply(result, namespaces), nil
+}
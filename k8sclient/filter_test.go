@@ -0,0 +1,207 @@
+package k8sclient
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetFilteredDeploymentIngressPaths(t *testing.T) {
+	deployment := func(namespace, name string, labels map[string]string) *v1.Deployment {
+		return &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(namespace + "/" + name), Labels: labels}}
+	}
+	clientset := fake.NewSimpleClientset(
+		&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"env": "prod"}}},
+		&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch", Labels: map[string]string{"env": "prod"}}},
+		&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		deployment("shop", "web", map[string]string{"team": "shop"}),
+		deployment("shop", "worker", map[string]string{"team": "batch"}),
+		deployment("batch", "cron", map[string]string{"team": "batch"}),
+		deployment("kube-system", "dns", nil),
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	tests := []struct {
+		name              string
+		namespaces        []string
+		exclude           []string
+		namespaceSelector string
+		selector          string
+		want              []string
+	}{
+		{
+			name: "Nothing - every workload",
+			want: []string{"batch/cron", "kube-system/dns", "shop/web", "shop/worker"},
+		},
+		{
+			name:       "Namespaces - only those listed",
+			namespaces: []string{"shop", "batch"},
+			want:       []string{"batch/cron", "shop/web", "shop/worker"},
+		},
+		{
+			name:    "Exclude - every other namespace",
+			exclude: []string{"kube-system"},
+			want:    []string{"batch/cron", "shop/web", "shop/worker"},
+		},
+		{
+			name:       "Exclude - wins over listed namespaces",
+			namespaces: []string{"shop", "kube-system"},
+			exclude:    []string{"kube-system"},
+			want:       []string{"shop/web", "shop/worker"},
+		},
+		{
+			name:              "NamespaceSelector - the namespaces with matching labels",
+			namespaceSelector: "env=prod",
+			exclude:           []string{"batch"},
+			want:              []string{"shop/web", "shop/worker"},
+		},
+		{
+			name:              "NamespaceSelector - nothing matches",
+			namespaceSelector: "env=dev",
+			want:              []string{},
+		},
+		{
+			name:     "Selector - the workloads with matching labels",
+			selector: "team=batch",
+			want:     []string{"batch/cron", "shop/worker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewFilter(tt.namespaces, tt.exclude, tt.namespaceSelector, tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := GetFilteredDeploymentIngressPaths(clientset, dynamicClient, []RouteResolver{}, filter, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, dip := range result.Paths {
+				got = append(got, NamespaceNameKey(dip.Workload))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetFilteredDeploymentIngressPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewFilter(nil, nil, "env in (prod", ""); err == nil {
+		t.Error("NewFilter() should reject an invalid namespace selector")
+	}
+}
+
+func TestGetFilteredDeploymentIngressPathsNodesListedOnce(t *testing.T) {
+	// selectorless services sending traffic outside of the cluster are reported as External workloads
+	nodePort := func(namespace string) (*apiv1.Service, *apiv1.Endpoints) {
+		svc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: namespace, UID: types.UID(namespace + "/legacy")}}
+		svc.Spec.Type = apiv1.ServiceTypeNodePort
+		svc.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}}
+		endpoints := &apiv1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: namespace}}
+		endpoints.Subsets = []apiv1.EndpointSubset{{Addresses: []apiv1.EndpointAddress{{IP: "192.0.2.10"}}}}
+		return svc, endpoints
+	}
+	node := &apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+	node.Status.Addresses = []apiv1.NodeAddress{{Type: apiv1.NodeExternalIP, Address: "203.0.113.1"}}
+	shopSvc, shopEndpoints := nodePort("shop")
+	batchSvc, batchEndpoints := nodePort("batch")
+	clientset := fake.NewSimpleClientset(node, shopSvc, shopEndpoints, batchSvc, batchEndpoints)
+
+	filter, err := NewFilter([]string{"shop", "batch"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := GetFilteredDeploymentIngressPaths(clientset, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), []RouteResolver{}, filter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := 0
+	for _, dip := range result.Paths {
+		for _, r := range dip.Routes {
+			if r.Kind == KindService && len(r.URLs) == 1 && r.URLs[0].Host == "203.0.113.1:30080" {
+				routes++
+			}
+		}
+	}
+	if routes != 2 {
+		t.Errorf("GetFilteredDeploymentIngressPaths() got %d node port routes, want 2 in %+v", routes, result.Paths)
+	}
+	lists := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "nodes" {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("GetFilteredDeploymentIngressPaths() listed nodes %d times, want once", lists)
+	}
+}
+
+func TestReadNamespaces(t *testing.T) {
+	read := func(namespace string) (Result, error) {
+		if namespace == "billing" || namespace == "batch" {
+			return NewResult(), fmt.Errorf("namespace %s is unavailable", namespace)
+		}
+		return Result{Paths: DeploymentIngressPaths{}, Warnings: []Warning{{Namespace: namespace, Resource: "services", Message: "read"}}}, nil
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		namespaces []string
+		warnings   []string
+		wantErr    bool
+	}{
+		{
+			name:       "Partial - the namespace that cannot be read is named in the warnings",
+			ctx:        context.Background(),
+			namespaces: []string{"shop", "billing"},
+			warnings:   []string{"shop", "billing"},
+		},
+		{
+			name:       "Nothing - fails when none of the namespaces can be read",
+			ctx:        context.Background(),
+			namespaces: []string{"billing", "batch"},
+			warnings:   []string{"billing", "batch"},
+			wantErr:    true,
+		},
+		{
+			name:       "Cancelled - nothing is read",
+			ctx:        cancelled,
+			namespaces: []string{"shop"},
+			warnings:   []string{},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ReadNamespaces(tt.ctx, tt.namespaces, read)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadNamespaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			warnings := []string{}
+			for _, w := range result.Warnings {
+				warnings = append(warnings, w.Namespace)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("ReadNamespaces() warnings = %v, want %v", warnings, tt.warnings)
+			}
+		})
+	}
+}
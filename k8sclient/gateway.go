@@ -0,0 +1,248 @@
+package k8sclient
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// KindHTTPRoute is the kind reported for routes built from Gateway API HTTPRoutes
+	KindHTTPRoute = "HTTPRoute"
+)
+
+var (
+	// GatewayGroupVersions are the Gateway API group versions, in order of preference
+	GatewayGroupVersions = []schema.GroupVersion{
+		{Group: "gateway.networking.k8s.io", Version: "v1"},
+		{Group: "gateway.networking.k8s.io", Version: "v1beta1"},
+		{Group: "gateway.networking.k8s.io", Version: "v1alpha2"},
+	}
+)
+
+// Gateway is the subset of a Gateway API Gateway needed to build URLs
+type Gateway struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		GatewayClassName string            `json:"gatewayClassName,omitempty"`
+		Listeners        []GatewayListener `json:"listeners,omitempty"`
+	} `json:"spec,omitempty"`
+	Status struct {
+		Addresses []GatewayAddress `json:"addresses,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// GatewayListener is a port, protocol and optional hostname a Gateway accepts traffic on
+type GatewayListener struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname,omitempty"`
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// GatewayAddress is an IP or hostname assigned to a Gateway
+type GatewayAddress struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`
+}
+
+// HTTPRoute is the subset of a Gateway API HTTPRoute needed to build routes
+type HTTPRoute struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		ParentRefs []GatewayParentRef `json:"parentRefs,omitempty"`
+		Hostnames  []string           `json:"hostnames,omitempty"`
+		Rules      []struct {
+			Matches []struct {
+				Path *struct {
+					Type  string `json:"type,omitempty"`
+					Value string `json:"value,omitempty"`
+				} `json:"path,omitempty"`
+			} `json:"matches,omitempty"`
+			BackendRefs []struct {
+				Group     *string `json:"group,omitempty"`
+				Kind      *string `json:"kind,omitempty"`
+				Name      string  `json:"name"`
+				Namespace *string `json:"namespace,omitempty"`
+				Port      *int32  `json:"port,omitempty"`
+				Weight    *int32  `json:"weight,omitempty"`
+			} `json:"backendRefs,omitempty"`
+		} `json:"rules,omitempty"`
+	} `json:"spec,omitempty"`
+}
+
+// GatewayParentRef references the Gateway, and optionally the listener, an HTTPRoute attaches to
+type GatewayParentRef struct {
+	Kind        *string `json:"kind,omitempty"`
+	Namespace   *string `json:"namespace,omitempty"`
+	Name        string  `json:"name"`
+	SectionName *string `json:"sectionName,omitempty"`
+}
+
+// HTTPRouteResolver returns a RouteResolver that lists HTTPRoutes and resolves their parent Gateways
+func HTTPRouteResolver(client dynamic.Interface, gv schema.GroupVersion) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		items, err := ListUnstructured(client.Resource(gv.WithResource("httproutes")).Namespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+
+		// gateways are fetched on demand as route parents often live in a shared namespace
+		getGateway := cachedGetter(client, gv.WithResource("gateways"))
+
+		routes := []Route{}
+		for _, item := range items {
+			hr := HTTPRoute{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &hr); err != nil {
+				return nil, err
+			}
+
+			parents := []string{}
+			listeners := []gatewayListenerAddress{}
+			for _, ref := range hr.Spec.ParentRefs {
+				if ref.Kind != nil && *ref.Kind != "Gateway" {
+					continue
+				}
+				gwNamespace := hr.Namespace
+				if ref.Namespace != nil {
+					gwNamespace = *ref.Namespace
+				}
+				parents = append(parents, gwNamespace+"/"+ref.Name)
+				obj := getGateway(gwNamespace, ref.Name)
+				if obj == nil {
+					continue
+				}
+				gw := &Gateway{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, gw); err != nil {
+					continue
+				}
+				listeners = append(listeners, gw.listenerAddresses(ref.SectionName)...)
+			}
+
+			for _, rule := range hr.Spec.Rules {
+				route := Route{
+					Kind:      KindHTTPRoute,
+					Name:      hr.Name,
+					Namespace: hr.Namespace,
+					Class:     strings.Join(parents, ","),
+				}
+
+				paths := []string{}
+				for _, m := range rule.Matches {
+					if m.Path != nil && len(m.Path.Value) != 0 {
+						paths = append(paths, m.Path.Value)
+					}
+				}
+				if len(paths) == 0 {
+					paths = append(paths, "/")
+				}
+				route.URLs = httpRouteURLs(listeners, hr.Spec.Hostnames, paths)
+
+				for _, ref := range rule.BackendRefs {
+					if ref.Kind != nil && *ref.Kind != "Service" {
+						continue
+					}
+					backend := RouteBackend{ServiceName: ref.Name, Weight: ref.Weight}
+					if ref.Namespace != nil {
+						backend.Namespace = *ref.Namespace
+					}
+					if ref.Port != nil {
+						backend.ServicePort = intstr.FromInt(int(*ref.Port))
+					}
+					route.Backends = append(route.Backends, backend)
+				}
+				// weights only mean something when traffic is split
+				if len(route.Backends) == 1 {
+					route.Backends[0].Weight = nil
+				}
+				routes = append(routes, route)
+			}
+		}
+		return routes, nil
+	}
+}
+
+// gatewayListenerAddress is a listener paired with the address of its gateway
+type gatewayListenerAddress struct {
+	GatewayListener
+	Address string
+}
+
+// listenerAddresses returns the HTTP and HTTPS listeners of the gateway, restricted to sectionName when set
+func (gw *Gateway) listenerAddresses(sectionName *string) []gatewayListenerAddress {
+	address := ""
+	if len(gw.Status.Addresses) != 0 {
+		address = gw.Status.Addresses[0].Value
+	}
+
+	result := []gatewayListenerAddress{}
+	for _, l := range gw.Spec.Listeners {
+		if sectionName != nil && *sectionName != l.Name {
+			continue
+		}
+		if l.Protocol != "HTTP" && l.Protocol != "HTTPS" {
+			continue
+		}
+		result = append(result, gatewayListenerAddress{GatewayListener: l, Address: address})
+	}
+	return result
+}
+
+// httpRouteURLs returns a URL for each listener, hostname and path combination.
+// Route hostnames are limited to those the listener accepts, the gateway address is used when neither sets one.
+func httpRouteURLs(listeners []gatewayListenerAddress, hostnames []string, paths []string) []url.URL {
+	urls := []url.URL{}
+	// without a resolvable gateway the hostnames are all we know
+	if len(listeners) == 0 {
+		for _, host := range hostnames {
+			for _, path := range paths {
+				urls = append(urls, url.URL{Scheme: "http", Host: host, Path: path})
+			}
+		}
+		return urls
+	}
+
+	for _, l := range listeners {
+		hosts := []string{}
+		for _, h := range hostnames {
+			if listenerAcceptsHostname(l.Hostname, h) {
+				hosts = append(hosts, h)
+			}
+		}
+		if len(hostnames) == 0 && len(l.Hostname) != 0 {
+			hosts = append(hosts, l.Hostname)
+		}
+		if len(hostnames) == 0 && len(l.Hostname) == 0 && len(l.Address) != 0 {
+			hosts = append(hosts, l.Address)
+		}
+
+		scheme := strings.ToLower(l.Protocol)
+		for _, host := range hosts {
+			if (scheme == "http" && l.Port != 80) || (scheme == "https" && l.Port != 443) {
+				host = net.JoinHostPort(host, strconv.Itoa(int(l.Port)))
+			}
+			for _, path := range paths {
+				urls = append(urls, url.URL{Scheme: scheme, Host: host, Path: path})
+			}
+		}
+	}
+	return urls
+}
+
+// listenerAcceptsHostname returns true if the route hostname matches the listener hostname, which may be a wildcard
+func listenerAcceptsHostname(listenerHostname, hostname string) bool {
+	if len(listenerHostname) == 0 || listenerHostname == hostname {
+		return true
+	}
+	if strings.HasPrefix(listenerHostname, "*.") {
+		return strings.HasSuffix(hostname, listenerHostname[1:])
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package k8sclient
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// testDynamicClient returns a fake dynamic client holding the objects of each resource.
+// The objects are created through their resource as the fake client cannot guess it for kinds such as Gateway.
+func testDynamicClient(t *testing.T, gv schema.GroupVersion, objects map[string][]map[string]interface{}) *dynamicfake.FakeDynamicClient {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	for resource, objs := range objects {
+		for _, obj := range objs {
+			u := &unstructured.Unstructured{Object: obj}
+			if _, err := client.Resource(gv.WithResource(resource)).Namespace(u.GetNamespace()).Create(u, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	client.ClearActions()
+	return client
+}
+
+// testRouteSummaries returns each route as its name, class, URLs and backends, e.g. `web default/web [http://a/] -> [web:80]`
+func testRouteSummaries(routes []Route) []string {
+	summaries := []string{}
+	for _, r := range routes {
+		urls := []string{}
+		for _, u := range r.URLs {
+			urls = append(urls, u.String())
+		}
+		backends := []string{}
+		for _, b := range r.Backends {
+			backend := b.ServiceName + ":" + b.ServicePort.String()
+			if len(b.Namespace) != 0 {
+				backend = b.Namespace + "/" + backend
+			}
+			if len(b.Subset) != 0 {
+				backend += "@" + b.Subset
+			}
+			if b.Weight != nil {
+				backend += fmt.Sprintf("=%d", *b.Weight)
+			}
+			backends = append(backends, backend)
+		}
+		summaries = append(summaries, fmt.Sprintf("%s %s [%s] -> [%s]", r.Name, r.Class, strings.Join(urls, " "), strings.Join(backends, " ")))
+	}
+	return summaries
+}
+
+func TestHTTPRouteResolver(t *testing.T) {
+	gv := GatewayGroupVersions[0]
+	gateway := func(namespace, name string, listeners ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": gv.String(),
+			"kind":       "Gateway",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec":       map[string]interface{}{"gatewayClassName": "example", "listeners": listeners},
+			"status":     map[string]interface{}{"addresses": []interface{}{map[string]interface{}{"type": "IPAddress", "value": "203.0.113.10"}}},
+		}
+	}
+	listener := func(name string, port int64, protocol, hostname string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "port": port, "protocol": protocol, "hostname": hostname}
+	}
+	httpRoute := func(name string, hostnames []interface{}, parentRef map[string]interface{}, rules ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": gv.String(),
+			"kind":       "HTTPRoute",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec":       map[string]interface{}{"parentRefs": []interface{}{parentRef}, "hostnames": hostnames, "rules": rules},
+		}
+	}
+	backendRef := func(namespace, name string, port, weight int64) map[string]interface{} {
+		ref := map[string]interface{}{"name": name, "port": port, "weight": weight}
+		if len(namespace) != 0 {
+			ref["namespace"] = namespace
+		}
+		return ref
+	}
+	shared := gateway("infra", "shared", listener("http", 80, "HTTP", ""), listener("https", 443, "HTTPS", "*.example.com"), listener("tcp", 5432, "TCP", ""))
+
+	tests := []struct {
+		name     string
+		gateways []map[string]interface{}
+		routes   []map[string]interface{}
+		want     []string
+		wantGets int
+	}{
+		{
+			name:     "Section name - only the named listener of a gateway in another namespace is used",
+			gateways: []map[string]interface{}{shared},
+			routes: []map[string]interface{}{
+				httpRoute("shop", []interface{}{"shop.example.com"}, map[string]interface{}{"name": "shared", "namespace": "infra", "sectionName": "https"},
+					map[string]interface{}{
+						"matches":     []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": "/cart"}}},
+						"backendRefs": []interface{}{backendRef("", "cart", 8080, 100)},
+					},
+				),
+			},
+			want:     []string{"shop infra/shared [https://shop.example.com/cart] -> [cart:8080]"},
+			wantGets: 1,
+		},
+		{
+			name:     "Listeners - every HTTP listener of the gateway is used, at the gateway address without hostnames",
+			gateways: []map[string]interface{}{gateway("default", "web", listener("http", 8080, "HTTP", ""), listener("tcp", 5432, "TCP", ""))},
+			routes: []map[string]interface{}{
+				httpRoute("web", nil, map[string]interface{}{"name": "web"}, map[string]interface{}{"backendRefs": []interface{}{backendRef("", "web", 80, 1)}}),
+			},
+			want:     []string{"web default/web [http://203.0.113.10:8080/] -> [web:80]"},
+			wantGets: 1,
+		},
+		{
+			name:     "Split traffic - weights are kept and backends may live in another namespace",
+			gateways: []map[string]interface{}{shared},
+			routes: []map[string]interface{}{
+				httpRoute("shop", []interface{}{"shop.example.com"}, map[string]interface{}{"name": "shared", "namespace": "infra", "sectionName": "http"},
+					map[string]interface{}{"backendRefs": []interface{}{backendRef("frontend", "web", 80, 90), backendRef("", "web-canary", 80, 10)}},
+				),
+			},
+			want:     []string{"shop infra/shared [http://shop.example.com/] -> [frontend/web:80=90 web-canary:80=10]"},
+			wantGets: 1,
+		},
+		{
+			name:     "Shared parent - the gateway is fetched once for every route and rule",
+			gateways: []map[string]interface{}{shared},
+			routes: []map[string]interface{}{
+				httpRoute("cart", []interface{}{"cart.example.com"}, map[string]interface{}{"name": "shared", "namespace": "infra", "sectionName": "https"},
+					map[string]interface{}{"backendRefs": []interface{}{backendRef("", "cart", 80, 1)}},
+					map[string]interface{}{
+						"matches":     []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "Exact", "value": "/healthz"}}},
+						"backendRefs": []interface{}{backendRef("", "health", 8080, 1)},
+					},
+				),
+				httpRoute("shop", []interface{}{"shop.example.com"}, map[string]interface{}{"name": "shared", "namespace": "infra", "sectionName": "https"},
+					map[string]interface{}{"backendRefs": []interface{}{backendRef("", "web", 80, 1)}},
+				),
+			},
+			want: []string{
+				"cart infra/shared [https://cart.example.com/] -> [cart:80]",
+				"cart infra/shared [https://cart.example.com/healthz] -> [health:8080]",
+				"shop infra/shared [https://shop.example.com/] -> [web:80]",
+			},
+			wantGets: 1,
+		},
+		{
+			name: "Missing gateway - the route hostnames are used and other backend kinds are left out",
+			routes: []map[string]interface{}{
+				httpRoute("shop", []interface{}{"shop.example.com"}, map[string]interface{}{"name": "missing"},
+					map[string]interface{}{"backendRefs": []interface{}{map[string]interface{}{"kind": "Bucket", "group": "storage.example.com", "name": "assets"}}},
+				),
+			},
+			want:     []string{"shop default/missing [http://shop.example.com/] -> []"},
+			wantGets: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testDynamicClient(t, gv, map[string][]map[string]interface{}{"gateways": tt.gateways, "httproutes": tt.routes})
+			routes, err := HTTPRouteResolver(client, gv)("default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := testRouteSummaries(routes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HTTPRouteResolver() = %v, want %v", got, tt.want)
+			}
+			gets := 0
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "get" && action.GetResource().Resource == "gateways" {
+					gets++
+				}
+			}
+			if gets != tt.wantGets {
+				t.Errorf("HTTPRouteResolver() got the gateway %d times, want %d", gets, tt.wantGets)
+			}
+		})
+	}
+}
+
+func TestHTTPRouteURLs(t *testing.T) {
+	gateway := func(listeners ...GatewayListener) []gatewayListenerAddress {
+		result := []gatewayListenerAddress{}
+		for _, l := range listeners {
+			result = append(result, gatewayListenerAddress{GatewayListener: l, Address: "203.0.113.10"})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		listeners []gatewayListenerAddress
+		hostnames []string
+		paths     []string
+		want      []string
+	}{
+		{
+			name:      "Hostnames - route hostnames are used on every listener",
+			listeners: gateway(GatewayListener{Name: "http", Port: 80, Protocol: "HTTP"}, GatewayListener{Name: "https", Port: 443, Protocol: "HTTPS"}),
+			hostnames: []string{"shop.example.com"},
+			paths:     []string{"/"},
+			want:      []string{"http://shop.example.com/", "https://shop.example.com/"},
+		},
+		{
+			name:      "Wildcard - only hostnames accepted by the listener are used",
+			listeners: gateway(GatewayListener{Name: "https", Hostname: "*.example.com", Port: 443, Protocol: "HTTPS"}),
+			hostnames: []string{"shop.example.com", "shop.example.org"},
+			paths:     []string{"/cart", "/checkout"},
+			want:      []string{"https://shop.example.com/cart", "https://shop.example.com/checkout"},
+		},
+		{
+			name:      "Address - the gateway address and non-default port are used without hostnames",
+			listeners: gateway(GatewayListener{Name: "http", Port: 8080, Protocol: "HTTP"}),
+			paths:     []string{"/"},
+			want:      []string{"http://203.0.113.10:8080/"},
+		},
+		{
+			name:      "Unresolved - route hostnames are used when the gateway is unknown",
+			hostnames: []string{"shop.example.com"},
+			paths:     []string{"/"},
+			want:      []string{"http://shop.example.com/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, u := range httpRouteURLs(tt.listeners, tt.hostnames, tt.paths) {
+				got = append(got, u.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("httpRouteURLs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package k8sclient
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PodIndex indexes pods by namespace and label so selectors are matched without scanning every pod
+type PodIndex struct {
+	pods []apiv1.Pod
+	// byNamespace and byLabel hold positions in pods, byLabel is keyed by namespace/key=value
+	byNamespace map[string][]int
+	byLabel     map[string][]int
+}
+
+// NewPodIndex returns an index of the pods
+func NewPodIndex(pods []apiv1.Pod) *PodIndex {
+	i := &PodIndex{
+		pods:        pods,
+		byNamespace: map[string][]int{},
+		byLabel:     map[string][]int{},
+	}
+	for n, p := range pods {
+		i.byNamespace[p.Namespace] = append(i.byNamespace[p.Namespace], n)
+		for k, v := range p.Labels {
+			key := labelKey(p.Namespace, k, v)
+			i.byLabel[key] = append(i.byLabel[key], n)
+		}
+	}
+	return i
+}
+
+func labelKey(namespace, key, value string) string {
+	return namespace + "/" + key + "=" + value
+}
+
+// Select returns the pods in the namespace matching the selector.
+// The candidates are narrowed to the pods carrying the rarest of matchLabels before the full selector is evaluated.
+func (i *PodIndex) Select(namespace string, selector *metav1.LabelSelector) []apiv1.Pod {
+	result := []apiv1.Pod{}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		zap.S().Errorf("invalid selector %s: %s", metav1.FormatLabelSelector(selector), err.Error())
+		return result
+	}
+
+	candidates := i.byNamespace[namespace]
+	if selector != nil {
+		for k, v := range selector.MatchLabels {
+			if positions := i.byLabel[labelKey(namespace, k, v)]; len(positions) < len(candidates) {
+				candidates = positions
+			}
+		}
+	}
+	for _, n := range candidates {
+		if s.Matches(labels.Set(i.pods[n].Labels)) {
+			result = append(result, i.pods[n])
+		}
+	}
+	return result
+}
+
+// WorkloadPods returns the pods of the workload
+func (i *PodIndex) WorkloadPods(workload Workload) []apiv1.Pod {
+	switch w := workload.(type) {
+	case PodWorkload:
+		// a bare pod is its own and only pod
+		return []apiv1.Pod{*w.Pod}
+	case ExternalWorkload:
+		return []apiv1.Pod{}
+	}
+	return i.Select(workload.GetNamespace(), workload.PodSelector())
+}
+
+// RouteIndex indexes routes by the namespace/name of the services they forward to
+type RouteIndex struct {
+	routes    []Route
+	byService map[string][]int
+}
+
+// NewRouteIndex returns an index of the routes
+func NewRouteIndex(routes []Route) RouteIndex {
+	i := RouteIndex{routes: routes, byService: map[string][]int{}}
+	for n, r := range routes {
+		for _, b := range r.Backends {
+			namespace := b.Namespace
+			if len(namespace) == 0 {
+				namespace = r.Namespace
+			}
+			key := namespace + "/" + b.ServiceName
+			if positions := i.byService[key]; len(positions) == 0 || positions[len(positions)-1] != n {
+				i.byService[key] = append(positions, n)
+			}
+		}
+	}
+	return i
+}
+
+// ServicesRoutes returns the routes that forward to any of the passed services, in the order they were indexed
+func (i RouteIndex) ServicesRoutes(services []apiv1.Service) []Route {
+	matched := map[int]bool{}
+	for _, s := range services {
+		for _, n := range i.byService[ServiceKey(s)] {
+			if !matched[n] && len(i.routes[n].ServiceBackends(s)) != 0 {
+				matched[n] = true
+			}
+		}
+	}
+
+	positions := []int{}
+	for n := range matched {
+		positions = append(positions, n)
+	}
+	sort.Ints(positions)
+
+	result := []Route{}
+	for _, n := range positions {
+		result = append(result, i.routes[n])
+	}
+	return result
+}
+
+// workloadIndex indexes workloads by their pods and by the labels of their pod templates
+type workloadIndex struct {
+	position map[string]int
+	byPod    map[string][]Workload
+	// byLabel is keyed by namespace/key=value
+	byLabel map[string][]Workload
+}
+
+func newWorkloadIndex(workloads []Workload, workloadPods map[string][]apiv1.Pod) workloadIndex {
+	i := workloadIndex{position: map[string]int{}, byPod: map[string][]Workload{}, byLabel: map[string][]Workload{}}
+	for n, w := range workloads {
+		i.position[WorkloadID(w)] = n
+		for _, p := range workloadPods[WorkloadID(w)] {
+			i.byPod[objectKey(&p)] = append(i.byPod[objectKey(&p)], w)
+		}
+		for k, v := range w.PodTemplate().Labels {
+			key := labelKey(w.GetNamespace(), k, v)
+			i.byLabel[key] = append(i.byLabel[key], w)
+		}
+	}
+	return i
+}
+
+// podsWorkloads returns the workloads owning any of the addresses' pods, in the order they were indexed
+func (i workloadIndex) podsWorkloads(addresses []EndpointAddress) []Workload {
+	seen := map[string]bool{}
+	result := []Workload{}
+	for _, a := range addresses {
+		if a.TargetRef == nil || a.TargetRef.Kind != KindPod {
+			continue
+		}
+		for _, w := range i.byPod[a.TargetRef.Namespace+"/"+a.TargetRef.Name] {
+			if !seen[WorkloadID(w)] {
+				seen[WorkloadID(w)] = true
+				result = append(result, w)
+			}
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return i.position[WorkloadID(result[a])] < i.position[WorkloadID(result[b])] })
+	return result
+}
+
+// selectedWorkloads returns the workloads whose pod template the service selects, in the order they were indexed
+func (i workloadIndex) selectedWorkloads(service apiv1.Service) []Workload {
+	result := []Workload{}
+	if len(service.Spec.Selector) == 0 {
+		return result
+	}
+
+	// every workload the selector matches carries each of its labels, so the rarest one bounds the candidates
+	var candidates []Workload
+	first := true
+	for k, v := range service.Spec.Selector {
+		if workloads := i.byLabel[labelKey(service.Namespace, k, v)]; first || len(workloads) < len(candidates) {
+			candidates = workloads
+			first = false
+		}
+	}
+
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	for _, w := range candidates {
+		if selector.Matches(labels.Set(w.PodTemplate().Labels)) {
+			result = append(result, w)
+		}
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testIndexDeployment(namespace, name string, labels map[string]string) DeploymentWorkload {
+	deployment := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	deployment.Spec.Template.Labels = labels
+	return DeploymentWorkload{deployment}
+}
+
+func workloadKeys(workloads []Workload) []string {
+	keys := []string{}
+	for _, w := range workloads {
+		keys = append(keys, NamespaceNameKey(w))
+	}
+	return keys
+}
+
+func TestGetServiceWorkloads(t *testing.T) {
+	web := testIndexDeployment("default", "web", map[string]string{"app": "web"})
+	workloadPods := map[string][]apiv1.Pod{
+		WorkloadID(web): {{ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default"}}},
+	}
+
+	service := apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	service.Spec.Selector = map[string]string{"app": "web"}
+	externalName := apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	externalName.Spec.Type = apiv1.ServiceTypeExternalName
+
+	podRef := func(name string) *apiv1.ObjectReference {
+		return &apiv1.ObjectReference{Kind: KindPod, Name: name, Namespace: "default"}
+	}
+
+	tests := []struct {
+		name      string
+		service   apiv1.Service
+		addresses []EndpointAddress
+		want      []string
+	}{
+		{
+			name:      "Endpoint - a not ready endpoint targeting the pod is still membership",
+			service:   service,
+			addresses: []EndpointAddress{{IP: "10.42.0.5", TargetRef: podRef("web-abc"), Ready: false}},
+			want:      []string{"default/web"},
+		},
+		{
+			name:      "Other pods - endpoints targeting other pods win over the selector",
+			service:   service,
+			addresses: []EndpointAddress{{IP: "10.42.0.6", TargetRef: podRef("web-canary"), Ready: true}},
+			want:      []string{},
+		},
+		{
+			name:    "No endpoints - the selector is used until endpoints exist",
+			service: service,
+			want:    []string{"default/web"},
+		},
+		{
+			name:      "Selectorless - manual endpoints outside the cluster do not back the workload",
+			service:   apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}},
+			addresses: []EndpointAddress{{IP: "192.0.2.10", Ready: true}},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints := ServiceEndpoints{}
+			if len(tt.addresses) != 0 {
+				endpoints[ServiceKey(tt.service)] = tt.addresses
+			}
+			got := GetServiceWorkloads([]apiv1.Service{tt.service}, endpoints, []Workload{web}, workloadPods)
+			if len(got) != 1 {
+				t.Fatalf("GetServiceWorkloads() = %v, want a single service", got)
+			}
+			if keys := workloadKeys(got[0].Workloads); !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("GetServiceWorkloads() workloads = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+
+	if got := GetServiceWorkloads([]apiv1.Service{externalName}, ServiceEndpoints{}, []Workload{web}, workloadPods); len(got) != 0 {
+		t.Errorf("GetServiceWorkloads() = %v, want ExternalName services left out", got)
+	}
+}
+
+func TestWorkloadIndexPodsWorkloads(t *testing.T) {
+	web := testIndexDeployment("default", "web", nil)
+	canary := testIndexDeployment("default", "canary", nil)
+	index := newWorkloadIndex([]Workload{web, canary}, map[string][]apiv1.Pod{
+		WorkloadID(web):    {{ObjectMeta: metav1.ObjectMeta{Name: "web-a", Namespace: "default"}}, {ObjectMeta: metav1.ObjectMeta{Name: "web-b", Namespace: "default"}}},
+		WorkloadID(canary): {{ObjectMeta: metav1.ObjectMeta{Name: "canary-a", Namespace: "default"}}},
+	})
+
+	podRef := func(namespace, name string) *apiv1.ObjectReference {
+		return &apiv1.ObjectReference{Kind: KindPod, Name: name, Namespace: namespace}
+	}
+
+	tests := []struct {
+		name      string
+		addresses []EndpointAddress
+		want      []string
+	}{
+		{
+			name:      "Order - the workloads are returned in the order they were indexed",
+			addresses: []EndpointAddress{{TargetRef: podRef("default", "canary-a")}, {TargetRef: podRef("default", "web-a")}},
+			want:      []string{"default/web", "default/canary"},
+		},
+		{
+			name:      "Several pods - a workload is only returned once",
+			addresses: []EndpointAddress{{TargetRef: podRef("default", "web-a")}, {TargetRef: podRef("default", "web-b")}},
+			want:      []string{"default/web"},
+		},
+		{
+			name:      "Other namespace - a pod of the same name is not the workload's",
+			addresses: []EndpointAddress{{TargetRef: podRef("shop", "web-a")}},
+			want:      []string{},
+		},
+		{
+			name:      "Not a pod - addresses without a pod target are ignored",
+			addresses: []EndpointAddress{{IP: "192.0.2.10"}, {TargetRef: &apiv1.ObjectReference{Kind: "Node", Name: "web-a", Namespace: "default"}}},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workloadKeys(index.podsWorkloads(tt.addresses)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("podsWorkloads() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadIndexSelectedWorkloads(t *testing.T) {
+	index := newWorkloadIndex([]Workload{
+		testIndexDeployment("default", "web", map[string]string{"app": "web", "track": "stable"}),
+		testIndexDeployment("default", "canary", map[string]string{"app": "web", "track": "canary"}),
+		testIndexDeployment("shop", "web", map[string]string{"app": "web", "track": "stable"}),
+	}, map[string][]apiv1.Pod{})
+
+	service := func(selector map[string]string) apiv1.Service {
+		svc := apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+		svc.Spec.Selector = selector
+		return svc
+	}
+
+	tests := []struct {
+		name    string
+		service apiv1.Service
+		want    []string
+	}{
+		{
+			name:    "Single label - every workload of the namespace carrying it",
+			service: service(map[string]string{"app": "web"}),
+			want:    []string{"default/web", "default/canary"},
+		},
+		{
+			name:    "Several labels - the workloads carrying all of them",
+			service: service(map[string]string{"app": "web", "track": "canary"}),
+			want:    []string{"default/canary"},
+		},
+		{
+			name:    "Unknown label - nothing",
+			service: service(map[string]string{"app": "web", "tier": "frontend"}),
+			want:    []string{},
+		},
+		{
+			name:    "Selectorless - nothing",
+			service: service(nil),
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workloadKeys(index.selectedWorkloads(tt.service)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectedWorkloads() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteIndexServicesRoutes(t *testing.T) {
+	service := func(namespace, name string) apiv1.Service {
+		svc := apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		svc.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+		return svc
+	}
+	route := func(name string, backends ...RouteBackend) Route {
+		return Route{Kind: KindIngress, Name: name, Namespace: "default", Backends: backends}
+	}
+	index := NewRouteIndex([]Route{
+		route("both", RouteBackend{ServiceName: "web"}, RouteBackend{ServiceName: "api"}),
+		route("api", RouteBackend{ServiceName: "api", ServicePort: intstr.FromInt(80)}),
+		route("admin", RouteBackend{ServiceName: "web", ServicePort: intstr.FromInt(8080)}),
+		route("shared", RouteBackend{Namespace: "shop", ServiceName: "web", ServicePort: intstr.FromString("http")}),
+	})
+
+	tests := []struct {
+		name     string
+		services []apiv1.Service
+		want     []string
+	}{
+		{
+			name:     "Port - a route to another port of the service is left out",
+			services: []apiv1.Service{service("default", "web")},
+			want:     []string{"both"},
+		},
+		{
+			name:     "Several services - a route to both is returned once, in the order the routes were indexed",
+			services: []apiv1.Service{service("default", "api"), service("default", "web")},
+			want:     []string{"both", "api"},
+		},
+		{
+			name:     "Backend namespace - the route forwards to a service of another namespace",
+			services: []apiv1.Service{service("shop", "web")},
+			want:     []string{"shared"},
+		},
+		{
+			name:     "No routes - nothing",
+			services: []apiv1.Service{service("default", "db")},
+			want:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range index.ServicesRoutes(tt.services) {
+				got = append(got, r.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServicesRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package k8sclient
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// InformerSyncTimeout is how long a read waits for a newly started informer to fill its cache.
+	// Resources that cannot be listed, e.g. forbidden ones, fail reads once it has passed.
+	InformerSyncTimeout = 30 * time.Second
+)
+
+// informerDynamicClient is a dynamic.Interface that answers List and Get from informer caches.
+// The informer of a resource is started the first time it is read, every other verb goes to the API server.
+type informerDynamicClient struct {
+	dynamic.Interface
+	namespace string
+	resync    time.Duration
+
+	lock      sync.Mutex
+	stopCh    <-chan struct{}
+	informers map[schema.GroupVersionResource]*resourceInformer
+}
+
+type resourceInformer struct {
+	informers.GenericInformer
+	started time.Time
+}
+
+func newInformerDynamicClient(client dynamic.Interface, namespace string, resync time.Duration) *informerDynamicClient {
+	return &informerDynamicClient{
+		Interface: client,
+		namespace: namespace,
+		resync:    resync,
+		informers: map[schema.GroupVersionResource]*resourceInformer{},
+	}
+}
+
+// start runs the informers that were read before the client was started, and every informer read after
+func (c *informerDynamicClient) start(stopCh <-chan struct{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stopCh = stopCh
+	for _, informer := range c.informers {
+		informer.started = time.Now()
+		go informer.Informer().Run(stopCh)
+	}
+}
+
+// lister returns a lister of the resource once its informer has synced
+func (c *informerDynamicClient) lister(resource schema.GroupVersionResource) (dynamiclister.Lister, error) {
+	c.lock.Lock()
+	informer, ok := c.informers[resource]
+	if !ok {
+		zap.S().Debugf("Starting an informer for %s", resource.String())
+		// the informer is built directly as the dynamic factory of this client-go release ignores the namespace
+		informer = &resourceInformer{
+			GenericInformer: dynamicinformer.NewFilteredDynamicInformer(c.Interface, resource, c.namespace, c.resync,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil),
+		}
+		c.informers[resource] = informer
+		if c.stopCh != nil {
+			informer.started = time.Now()
+			go informer.Informer().Run(c.stopCh)
+		}
+	}
+	started := c.stopCh != nil
+	c.lock.Unlock()
+
+	if !started {
+		return nil, fmt.Errorf("the informer for %s has not been started", resource.String())
+	}
+	if !informer.Informer().HasSynced() {
+		timeout := time.Until(informer.started.Add(InformerSyncTimeout))
+		if timeout <= 0 || wait.PollImmediate(100*time.Millisecond, timeout, func() (bool, error) {
+			return informer.Informer().HasSynced(), nil
+		}) != nil {
+			return nil, fmt.Errorf("the informer for %s has not synced", resource.String())
+		}
+	}
+	return dynamiclister.New(informer.Informer().GetIndexer(), resource), nil
+}
+
+// cached is whether the informers hold the objects of the namespace, they only watch the client's namespace when it has one
+func (c *informerDynamicClient) cached(namespace string) bool {
+	return len(c.namespace) == 0 || namespace == c.namespace
+}
+
+func (c *informerDynamicClient) list(resource schema.GroupVersionResource, namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	lister, err := c.lister(resource)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []*unstructured.Unstructured
+	if len(namespace) == 0 {
+		objs, err = lister.List(selector)
+	} else {
+		objs, err = lister.Namespace(namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	for _, obj := range objs {
+		list.Items = append(list.Items, *obj.DeepCopy())
+	}
+	// caches are unordered, sort like the API server does so output is stable between reads
+	sort.Slice(list.Items, func(i, j int) bool {
+		return objectKey(&list.Items[i]) < objectKey(&list.Items[j])
+	})
+	return list, nil
+}
+
+func (c *informerDynamicClient) get(resource schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	lister, err := c.lister(resource)
+	if err != nil {
+		return nil, err
+	}
+	var obj *unstructured.Unstructured
+	if len(namespace) == 0 {
+		obj, err = lister.Get(name)
+	} else {
+		obj, err = lister.Namespace(namespace).Get(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj.DeepCopy(), nil
+}
+
+// Resource returns an interface for the resource whose List and Get read from the resource's informer
+func (c *informerDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &informerResource{NamespaceableResourceInterface: c.Interface.Resource(resource), client: c, resource: resource}
+}
+
+type informerResource struct {
+	dynamic.NamespaceableResourceInterface
+	client   *informerDynamicClient
+	resource schema.GroupVersionResource
+}
+
+func (r *informerResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &informerNamespacedResource{
+		ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace),
+		client:            r.client,
+		resource:          r.resource,
+		namespace:         namespace,
+	}
+}
+
+func (r *informerResource) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return r.client.list(r.resource, "", opts)
+}
+
+func (r *informerResource) Get(name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(subresources) != 0 {
+		return r.NamespaceableResourceInterface.Get(name, opts, subresources...)
+	}
+	return r.client.get(r.resource, "", name)
+}
+
+type informerNamespacedResource struct {
+	dynamic.ResourceInterface
+	client    *informerDynamicClient
+	resource  schema.GroupVersionResource
+	namespace string
+}
+
+func (r *informerNamespacedResource) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if !r.client.cached(r.namespace) {
+		return r.ResourceInterface.List(opts)
+	}
+	return r.client.list(r.resource, r.namespace, opts)
+}
+
+// Get reads objects outside of the client's namespace, e.g. a Gateway shared by the routes of several namespaces, from the API server
+func (r *informerNamespacedResource) Get(name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(subresources) != 0 || !r.client.cached(r.namespace) {
+		return r.ResourceInterface.Get(name, opts, subresources...)
+	}
+	return r.client.get(r.resource, r.namespace, name)
+}
+
+// objectKey returns the namespace/name of the object
+func objectKey(obj metav1.Object) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
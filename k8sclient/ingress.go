@@ -4,68 +4,277 @@ import (
 	"net/url"
 	"strings"
 
-	v1beta1 "k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 )
 
-// IngressURLs returns a slice of URLs that are captured by the Ingress
-// supports external-dns annotation external-dns.alpha.kubernetes.io/hostname=fqdn.
-func IngressURLs(ing v1beta1.Ingress) []url.URL {
-	urls := []url.URL{}
-	externalHost := IngressExternalDNSName(&ing)
-	statusName := IngressStatusName(&ing)
-	for _, rule := range ing.Spec.Rules {
-		url := url.URL{Scheme: "http"}
-		if IngressHostTLS(rule.Host, ing.Spec.TLS) {
-			url.Scheme = "https"
+const (
+	// IngressCatchAllPath is the path reported for traffic that falls through to the default backend
+	IngressCatchAllPath = "/*"
+)
+
+var (
+	// IngressGroupVersions are the group versions that serve Ingresses, in order of preference
+	IngressGroupVersions = []schema.GroupVersion{
+		{Group: "networking.k8s.io", Version: "v1"},
+		{Group: "networking.k8s.io", Version: "v1beta1"},
+		{Group: "extensions", Version: "v1beta1"},
+	}
+)
+
+// Ingress is the version independent route model of an Ingress.
+// It is normalized from whichever Ingress API version the cluster serves.
+type Ingress struct {
+	metav1.ObjectMeta
+	APIVersion string
+
+	// ClassName is spec.ingressClassName, falling back to the kubernetes.io/ingress.class annotation
+	ClassName      string
+	DefaultBackend *IngressBackend
+	Rules          []IngressRule
+	TLS            []IngressTLS
+	LoadBalancer   []apiv1.LoadBalancerIngress
+}
+
+// IngressRule is a host and the paths routed under it
+type IngressRule struct {
+	Host  string
+	Paths []IngressPath
+}
+
+// IngressPath is a single path of an IngressRule and the backend it routes to
+type IngressPath struct {
+	Path     string
+	PathType string
+	Backend  IngressBackend
+}
+
+// IngressBackend is a service and port. The port is a number or, for networking.k8s.io/v1, may be a port name.
+type IngressBackend struct {
+	ServiceName string
+	ServicePort intstr.IntOrString
+}
+
+// IngressTLS is the set of hosts covered by a TLS secret
+type IngressTLS struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	SecretName string   `json:"secretName,omitempty"`
+}
+
+// ingressObject is the union of the extensions/v1beta1, networking.k8s.io/v1beta1 and networking.k8s.io/v1 Ingress shapes
+type ingressObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		IngressClassName *string               `json:"ingressClassName,omitempty"`
+		Backend          *ingressBackendObject `json:"backend,omitempty"`
+		DefaultBackend   *ingressBackendObject `json:"defaultBackend,omitempty"`
+		TLS              []IngressTLS          `json:"tls,omitempty"`
+		Rules            []struct {
+			Host string `json:"host,omitempty"`
+			HTTP *struct {
+				Paths []struct {
+					Path     string               `json:"path,omitempty"`
+					PathType *string              `json:"pathType,omitempty"`
+					Backend  ingressBackendObject `json:"backend"`
+				} `json:"paths"`
+			} `json:"http,omitempty"`
+		} `json:"rules,omitempty"`
+	} `json:"spec,omitempty"`
+	Status struct {
+		LoadBalancer apiv1.LoadBalancerStatus `json:"loadBalancer,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// ingressBackendObject holds both the v1beta1 serviceName/servicePort and the v1 service.name/service.port shapes
+type ingressBackendObject struct {
+	ServiceName string             `json:"serviceName,omitempty"`
+	ServicePort intstr.IntOrString `json:"servicePort,omitempty"`
+	Service     *struct {
+		Name string `json:"name"`
+		Port struct {
+			Name   string `json:"name,omitempty"`
+			Number int32  `json:"number,omitempty"`
+		} `json:"port,omitempty"`
+	} `json:"service,omitempty"`
+}
+
+func (b ingressBackendObject) normalize() IngressBackend {
+	if b.Service == nil {
+		return IngressBackend{ServiceName: b.ServiceName, ServicePort: b.ServicePort}
+	}
+	backend := IngressBackend{ServiceName: b.Service.Name, ServicePort: intstr.FromInt(int(b.Service.Port.Number))}
+	if len(b.Service.Port.Name) != 0 {
+		backend.ServicePort = intstr.FromString(b.Service.Port.Name)
+	}
+	return backend
+}
+
+// NewIngress normalizes an Ingress of any served API version
+func NewIngress(obj *unstructured.Unstructured) (Ingress, error) {
+	raw := ingressObject{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &raw); err != nil {
+		return Ingress{}, err
+	}
+
+	ing := Ingress{
+		ObjectMeta:   raw.ObjectMeta,
+		APIVersion:   raw.APIVersion,
+		ClassName:    raw.Annotations[IngressClassAnnotation],
+		TLS:          raw.Spec.TLS,
+		LoadBalancer: raw.Status.LoadBalancer.Ingress,
+	}
+	if raw.Spec.IngressClassName != nil {
+		ing.ClassName = *raw.Spec.IngressClassName
+	}
+
+	switch {
+	case raw.Spec.DefaultBackend != nil:
+		backend := raw.Spec.DefaultBackend.normalize()
+		ing.DefaultBackend = &backend
+	case raw.Spec.Backend != nil:
+		backend := raw.Spec.Backend.normalize()
+		ing.DefaultBackend = &backend
+	}
+
+	for _, r := range raw.Spec.Rules {
+		rule := IngressRule{Host: r.Host}
+		if r.HTTP != nil {
+			for _, p := range r.HTTP.Paths {
+				path := IngressPath{Path: p.Path, Backend: p.Backend.normalize()}
+				if p.PathType != nil {
+					path.PathType = *p.PathType
+				}
+				rule.Paths = append(rule.Paths, path)
+			}
 		}
+		ing.Rules = append(ing.Rules, rule)
+	}
+	return ing, nil
+}
 
-		// default to information reported by the Ingress status
-		// items like external-dns and ingress controllers use this for backend info
-		url.Host = statusName
-		// if the rule has a host set, then use it
-		if len(rule.Host) != 0 {
-			url.Host = rule.Host
+// IngressResource uses discovery to find the preferred Ingress resource served by the cluster
+func IngressResource(client discovery.DiscoveryInterface) (schema.GroupVersionResource, error) {
+	gv, err := PreferredGroupVersion(client, IngressGroupVersions, "ingresses")
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	return gv.WithResource("ingresses"), nil
+}
+
+// ListIngresses returns the normalized Ingresses in the namespace
+func ListIngresses(client dynamic.Interface, resource schema.GroupVersionResource, namespace string) ([]Ingress, error) {
+	items, err := ListUnstructured(client.Resource(resource).Namespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Ingress{}
+	for i := range items {
+		ing, err := NewIngress(&items[i])
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, ing)
+	}
+	return result, nil
+}
 
-		// if the externalHost is set, then use that
-		if len(externalHost) != 0 {
-			url.Host = externalHost
+// IngressURLs returns a URL for every host and path combination that is captured by the Ingress
+// supports external-dns annotation external-dns.alpha.kubernetes.io/hostname=fqdn.
+func IngressURLs(ing Ingress) []url.URL {
+	urls := []url.URL{}
+	for _, rule := range ing.Rules {
+		for _, path := range rule.Paths {
+			urls = append(urls, IngressPathURL(ing, rule, path))
 		}
+	}
+	return append(urls, IngressDefaultBackendURLs(ing)...)
+}
+
+// IngressDefaultBackendURLs returns the catch-all URLs served by the default backend of the Ingress.
+// These are any path on the status/external-dns host, and any path of the rules that have no http paths.
+func IngressDefaultBackendURLs(ing Ingress) []url.URL {
+	urls := []url.URL{}
+	if ing.DefaultBackend == nil {
+		return urls
+	}
+
+	catchAll := IngressPathURL(ing, IngressRule{}, IngressPath{Path: IngressCatchAllPath})
+	if len(catchAll.Host) == 0 {
+		catchAll.Host = "*"
+	}
+	urls = append(urls, catchAll)
 
-		for _, path := range rule.HTTP.Paths {
-			url.Path = path.Path
+	for _, rule := range ing.Rules {
+		if len(rule.Paths) != 0 || len(rule.Host) == 0 {
+			continue
 		}
-		urls = append(urls, url)
+		urls = append(urls, IngressPathURL(ing, rule, IngressPath{Path: IngressCatchAllPath}))
 	}
 	return urls
 }
 
+// IngressPathURL returns the URL captured by a single path of an Ingress rule
+func IngressPathURL(ing Ingress, rule IngressRule, path IngressPath) url.URL {
+	url := url.URL{Scheme: "http", Path: path.Path}
+	if IngressHostTLS(rule.Host, ing.TLS) {
+		url.Scheme = "https"
+	}
+
+	// default to information reported by the Ingress status
+	// items like external-dns and ingress controllers use this for backend info
+	url.Host = IngressStatusName(&ing)
+	// if the rule has a host set, then use it
+	if len(rule.Host) != 0 {
+		url.Host = rule.Host
+	}
+
+	// if the externalHost is set, then use that
+	if externalHost := IngressExternalDNSName(&ing); len(externalHost) != 0 {
+		url.Host = externalHost
+	}
+	return url
+}
+
 // IngressExternalDNSName returns the value of the external-dns annotation
-func IngressExternalDNSName(ing *v1beta1.Ingress) string {
+func IngressExternalDNSName(ing *Ingress) string {
 	// trim the trailing `.` - assumes external-dns is not configured for default domain appending
 	// as such, if this is the case we're also going to assume that that said domain is in the search
 	// configuration for hosts that would have access to this information.
-	return strings.Trim(ing.GetObjectMeta().GetAnnotations()[ExternalDNSHostnameAnnotation], ".")
+	return strings.Trim(ing.GetAnnotations()[ExternalDNSHostnameAnnotation], ".")
 }
 
 // IngressStatusName returns the first available hostname or IP reported in the status field
-func IngressStatusName(ing *v1beta1.Ingress) string {
-	if len(ing.Status.LoadBalancer.Ingress) == 0 {
+func IngressStatusName(ing *Ingress) string {
+	if len(ing.LoadBalancer) == 0 {
 		return ""
 	}
-	if len(ing.Status.LoadBalancer.Ingress[0].Hostname) != 0 {
-		return ing.Status.LoadBalancer.Ingress[0].Hostname
+	if len(ing.LoadBalancer[0].Hostname) != 0 {
+		return ing.LoadBalancer[0].Hostname
 	}
-	return ing.Status.LoadBalancer.Ingress[0].IP
+	return ing.LoadBalancer[0].IP
 }
 
-// IngressHostTLS returns true if the path has a corresponding TLS host entry
-func IngressHostTLS(needle string, ingTLSs []v1beta1.IngressTLS) bool {
+// IngressHostTLS returns true if the path has a corresponding TLS host entry.
+// A wildcard entry such as `*.example.com` covers hosts one label below it.
+func IngressHostTLS(needle string, ingTLSs []IngressTLS) bool {
 	for _, ingTLS := range ingTLSs {
 		for _, host := range ingTLS.Hosts {
 			if host == needle {
 				return true
 			}
+			if strings.HasPrefix(host, "*.") {
+				if i := strings.Index(needle, "."); i > 0 && needle[i:] == host[1:] {
+					return true
+				}
+			}
 		}
 	}
 	return false
@@ -0,0 +1,295 @@
+package k8sclient
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNewIngress(t *testing.T) {
+	tests := []struct {
+		name      string
+		obj       map[string]interface{}
+		wantClass string
+		wantPaths []IngressPath
+	}{
+		{
+			name: "extensions/v1beta1 - serviceName and servicePort with the class annotation",
+			obj: map[string]interface{}{
+				"apiVersion": "extensions/v1beta1",
+				"kind":       "Ingress",
+				"metadata": map[string]interface{}{
+					"name":        "web",
+					"annotations": map[string]interface{}{IngressClassAnnotation: "traefik"},
+				},
+				"spec": map[string]interface{}{
+					"rules": []interface{}{
+						map[string]interface{}{
+							"host": "web.example.com",
+							"http": map[string]interface{}{
+								"paths": []interface{}{
+									map[string]interface{}{
+										"path":    "/",
+										"backend": map[string]interface{}{"serviceName": "web", "servicePort": int64(80)},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantClass: "traefik",
+			wantPaths: []IngressPath{
+				{Path: "/", Backend: IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(80)}},
+			},
+		},
+		{
+			name: "networking.k8s.io/v1 - service port name and number with ingressClassName and pathType",
+			obj: map[string]interface{}{
+				"apiVersion": "networking.k8s.io/v1",
+				"kind":       "Ingress",
+				"metadata": map[string]interface{}{
+					"name":        "web",
+					"annotations": map[string]interface{}{IngressClassAnnotation: "ignored"},
+				},
+				"spec": map[string]interface{}{
+					"ingressClassName": "nginx",
+					"rules": []interface{}{
+						map[string]interface{}{
+							"host": "web.example.com",
+							"http": map[string]interface{}{
+								"paths": []interface{}{
+									map[string]interface{}{
+										"path":     "/api",
+										"pathType": "Prefix",
+										"backend": map[string]interface{}{
+											"service": map[string]interface{}{
+												"name": "api",
+												"port": map[string]interface{}{"number": int64(8080)},
+											},
+										},
+									},
+									map[string]interface{}{
+										"path":     "/",
+										"pathType": "Exact",
+										"backend": map[string]interface{}{
+											"service": map[string]interface{}{
+												"name": "web",
+												"port": map[string]interface{}{"name": "http"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantClass: "nginx",
+			wantPaths: []IngressPath{
+				{Path: "/api", PathType: "Prefix", Backend: IngressBackend{ServiceName: "api", ServicePort: intstr.FromInt(8080)}},
+				{Path: "/", PathType: "Exact", Backend: IngressBackend{ServiceName: "web", ServicePort: intstr.FromString("http")}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing, err := NewIngress(&unstructured.Unstructured{Object: tt.obj})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ing.ClassName != tt.wantClass {
+				t.Errorf("NewIngress() ClassName = %v, want %v", ing.ClassName, tt.wantClass)
+			}
+			if len(ing.Rules) != 1 {
+				t.Fatalf("NewIngress() got %d rules, want 1", len(ing.Rules))
+			}
+			if !reflect.DeepEqual(ing.Rules[0].Paths, tt.wantPaths) {
+				t.Errorf("NewIngress() Paths = %+v, want %+v", ing.Rules[0].Paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestIngressRoutes(t *testing.T) {
+	ing := Ingress{
+		ClassName: "nginx",
+		TLS:       []IngressTLS{{Hosts: []string{"shop.example.com"}}},
+		Rules: []IngressRule{
+			{
+				Host: "shop.example.com",
+				Paths: []IngressPath{
+					{Path: "/api", PathType: "Prefix", Backend: IngressBackend{ServiceName: "api", ServicePort: intstr.FromInt(8080)}},
+					{Path: "/admin", PathType: "Exact", Backend: IngressBackend{ServiceName: "admin", ServicePort: intstr.FromInt(80)}},
+					{Path: "/", PathType: "Prefix", Backend: IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(80)}},
+				},
+			},
+			// a rule without an http block routes nothing
+			{Host: "empty.example.com"},
+		},
+	}
+	ing.Name = "shop"
+
+	urls := []string{}
+	for _, u := range IngressURLs(ing) {
+		urls = append(urls, u.String())
+	}
+	if want := []string{"https://shop.example.com/api", "https://shop.example.com/admin", "https://shop.example.com/"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("IngressURLs() = %v, want %v", urls, want)
+	}
+
+	routes := IngressRoutes(ing)
+	if len(routes) != 3 {
+		t.Fatalf("IngressRoutes() got %d routes, want 3", len(routes))
+	}
+	if routes[1].PathType != "Exact" || routes[1].Backends[0].ServiceName != "admin" || routes[1].URLs[0].Path != "/admin" {
+		t.Errorf("IngressRoutes() second route = %+v, want /admin (Exact) to admin", routes[1])
+	}
+}
+
+func TestIngressDefaultBackend(t *testing.T) {
+	ing := Ingress{
+		DefaultBackend: &IngressBackend{ServiceName: "fallback", ServicePort: intstr.FromInt(80)},
+		Rules: []IngressRule{
+			{Host: "shop.example.com", Paths: []IngressPath{{Path: "/", Backend: IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(80)}}}},
+			{Host: "legacy.example.com"},
+		},
+	}
+	ing.Name = "shop"
+
+	routes := IngressRoutes(ing)
+	if len(routes) != 2 {
+		t.Fatalf("IngressRoutes() got %d routes, want 2", len(routes))
+	}
+	def := routes[1]
+	if !def.DefaultBackend || def.Backends[0].ServiceName != "fallback" {
+		t.Errorf("IngressRoutes() default route = %+v, want a default backend route to fallback", def)
+	}
+
+	urls := []string{}
+	for _, u := range def.URLs {
+		link, _ := url.PathUnescape(u.String())
+		urls = append(urls, link)
+	}
+	if want := []string{"http://*/*", "http://legacy.example.com/*"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("default backend URLs = %v, want %v", urls, want)
+	}
+}
+
+func TestIngressURLs(t *testing.T) {
+	backend := IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(80)}
+	rule := func(host string) []IngressRule {
+		return []IngressRule{{Host: host, Paths: []IngressPath{{Path: "/", Backend: backend}}}}
+	}
+
+	tests := []struct {
+		name string
+		ing  Ingress
+		want []string
+	}{
+		{
+			name: "Plain - a rule without tls is http",
+			ing:  Ingress{Rules: rule("web.example.com")},
+			want: []string{"http://web.example.com/"},
+		},
+		{
+			name: "TLS - a rule whose host has a tls entry is https",
+			ing:  Ingress{Rules: rule("web.example.com"), TLS: []IngressTLS{{Hosts: []string{"web.example.com"}}}},
+			want: []string{"https://web.example.com/"},
+		},
+		{
+			name: "Other TLS host - tls for another host does not apply",
+			ing:  Ingress{Rules: rule("web.example.com"), TLS: []IngressTLS{{Hosts: []string{"api.example.com"}}}},
+			want: []string{"http://web.example.com/"},
+		},
+		{
+			name: "Wildcard TLS - a wildcard entry covers hosts one label below it",
+			ing:  Ingress{Rules: rule("web.example.com"), TLS: []IngressTLS{{Hosts: []string{"*.example.com"}}}},
+			want: []string{"https://web.example.com/"},
+		},
+		{
+			name: "Wildcard TLS depth - a wildcard entry does not cover deeper hosts",
+			ing:  Ingress{Rules: rule("a.web.example.com"), TLS: []IngressTLS{{Hosts: []string{"*.example.com"}}}},
+			want: []string{"http://a.web.example.com/"},
+		},
+		{
+			name: "Status hostname - a rule without a host uses the load balancer hostname",
+			ing:  Ingress{Rules: rule(""), LoadBalancer: []apiv1.LoadBalancerIngress{{Hostname: "lb.example.com"}}},
+			want: []string{"http://lb.example.com/"},
+		},
+		{
+			name: "Status IP - a rule without a host uses the load balancer IP when there is no hostname",
+			ing:  Ingress{Rules: rule(""), LoadBalancer: []apiv1.LoadBalancerIngress{{IP: "203.0.113.10"}}},
+			want: []string{"http://203.0.113.10/"},
+		},
+		{
+			name: "External DNS - the annotation wins over the rule host, without its trailing dot",
+			ing: Ingress{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ExternalDNSHostnameAnnotation: "shop.example.com."}},
+				Rules:      rule("web.example.com"),
+			},
+			want: []string{"http://shop.example.com/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls := []string{}
+			for _, u := range IngressURLs(tt.ing) {
+				urls = append(urls, u.String())
+			}
+			if !reflect.DeepEqual(urls, tt.want) {
+				t.Errorf("IngressURLs() = %v, want %v", urls, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIngressTLSAndStatus(t *testing.T) {
+	ing, err := NewIngress(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "Ingress",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"tls": []interface{}{
+				map[string]interface{}{"hosts": []interface{}{"web.example.com"}, "secretName": "web-tls"},
+			},
+			"rules": []interface{}{
+				map[string]interface{}{
+					"host": "web.example.com",
+					"http": map[string]interface{}{
+						"paths": []interface{}{
+							map[string]interface{}{
+								"path":     "/",
+								"pathType": "Prefix",
+								"backend": map[string]interface{}{
+									"service": map[string]interface{}{"name": "web", "port": map[string]interface{}{"number": int64(80)}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"ingress": []interface{}{map[string]interface{}{"ip": "203.0.113.10"}},
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IngressStatusName(&ing) != "203.0.113.10" {
+		t.Errorf("IngressStatusName() = %q, want 203.0.113.10", IngressStatusName(&ing))
+	}
+	if urls := IngressURLs(ing); len(urls) != 1 || urls[0].String() != "https://web.example.com/" {
+		t.Errorf("IngressURLs() = %v, want https://web.example.com/", urls)
+	}
+}
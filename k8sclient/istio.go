@@ -0,0 +1,243 @@
+package k8sclient
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// KindIstioVirtualService is the kind reported for routes built from Istio VirtualServices
+	KindIstioVirtualService = "VirtualService"
+
+	// istioMeshGateway is the reserved gateway name for sidecar to sidecar traffic
+	istioMeshGateway = "mesh"
+)
+
+var (
+	// IstioGroupVersions are the Istio networking group versions, in order of preference
+	IstioGroupVersions = []schema.GroupVersion{
+		{Group: "networking.istio.io", Version: "v1"},
+		{Group: "networking.istio.io", Version: "v1beta1"},
+		{Group: "networking.istio.io", Version: "v1alpha3"},
+	}
+)
+
+// IstioGateway is the subset of an Istio Gateway needed to build URLs
+type IstioGateway struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Servers []IstioServer `json:"servers,omitempty"`
+	} `json:"spec,omitempty"`
+}
+
+// IstioServer is a port and the hosts an Istio Gateway exposes on it
+type IstioServer struct {
+	Port struct {
+		Number   int32  `json:"number"`
+		Protocol string `json:"protocol"`
+		Name     string `json:"name,omitempty"`
+	} `json:"port"`
+	Hosts []string `json:"hosts"`
+	TLS   *struct {
+		Mode string `json:"mode,omitempty"`
+	} `json:"tls,omitempty"`
+}
+
+// IstioVirtualService is the subset of an Istio VirtualService needed to build routes
+type IstioVirtualService struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Hosts    []string `json:"hosts,omitempty"`
+		Gateways []string `json:"gateways,omitempty"`
+		HTTP     []struct {
+			Name  string `json:"name,omitempty"`
+			Match []struct {
+				URI *struct {
+					Exact  string `json:"exact,omitempty"`
+					Prefix string `json:"prefix,omitempty"`
+					Regex  string `json:"regex,omitempty"`
+				} `json:"uri,omitempty"`
+			} `json:"match,omitempty"`
+			Route []struct {
+				Destination struct {
+					Host   string `json:"host"`
+					Subset string `json:"subset,omitempty"`
+					Port   *struct {
+						Number int32 `json:"number,omitempty"`
+					} `json:"port,omitempty"`
+				} `json:"destination"`
+				Weight *int32 `json:"weight,omitempty"`
+			} `json:"route,omitempty"`
+		} `json:"http,omitempty"`
+	} `json:"spec,omitempty"`
+}
+
+// IstioVirtualServiceResolver returns a RouteResolver that lists VirtualServices bound to Istio Gateways.
+// VirtualServices that only apply to the mesh are not external entry points and are skipped.
+func IstioVirtualServiceResolver(client dynamic.Interface, gv schema.GroupVersion) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		items, err := ListUnstructured(client.Resource(gv.WithResource("virtualservices")).Namespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+
+		getGateway := cachedGetter(client, gv.WithResource("gateways"))
+
+		routes := []Route{}
+		for _, item := range items {
+			vs := IstioVirtualService{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &vs); err != nil {
+				return nil, err
+			}
+
+			gateways := []string{}
+			servers := []IstioServer{}
+			for _, ref := range vs.Spec.Gateways {
+				if ref == istioMeshGateway {
+					continue
+				}
+				gwNamespace, gwName := vs.Namespace, ref
+				if i := strings.Index(ref, "/"); i != -1 {
+					gwNamespace, gwName = ref[:i], ref[i+1:]
+				}
+				gateways = append(gateways, gwNamespace+"/"+gwName)
+				obj := getGateway(gwNamespace, gwName)
+				if obj == nil {
+					continue
+				}
+				gw := IstioGateway{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &gw); err != nil {
+					continue
+				}
+				servers = append(servers, gw.Spec.Servers...)
+			}
+			if len(gateways) == 0 {
+				continue
+			}
+
+			for _, h := range vs.Spec.HTTP {
+				route := Route{
+					Kind:      KindIstioVirtualService,
+					Name:      vs.Name,
+					Namespace: vs.Namespace,
+					Class:     "istio " + strings.Join(gateways, ","),
+				}
+
+				paths := []string{}
+				for _, m := range h.Match {
+					if m.URI == nil {
+						continue
+					}
+					switch {
+					case len(m.URI.Prefix) != 0:
+						paths = append(paths, m.URI.Prefix)
+					case len(m.URI.Exact) != 0:
+						paths = append(paths, m.URI.Exact)
+					case len(m.URI.Regex) != 0:
+						paths = append(paths, m.URI.Regex)
+					}
+				}
+				if len(paths) == 0 {
+					paths = append(paths, "/")
+				}
+				route.URLs = istioURLs(servers, vs.Spec.Hosts, paths)
+
+				for _, dest := range h.Route {
+					backendNamespace, serviceName, ok := IstioDestinationService(dest.Destination.Host, vs.Namespace)
+					if !ok {
+						continue
+					}
+					backend := RouteBackend{
+						Namespace:   backendNamespace,
+						ServiceName: serviceName,
+						Subset:      dest.Destination.Subset,
+						Weight:      dest.Weight,
+					}
+					if dest.Destination.Port != nil && dest.Destination.Port.Number != 0 {
+						backend.ServicePort = intstr.FromInt(int(dest.Destination.Port.Number))
+					}
+					route.Backends = append(route.Backends, backend)
+				}
+				if len(h.Route) == 1 && len(route.Backends) == 1 {
+					route.Backends[0].Weight = nil
+				}
+				routes = append(routes, route)
+			}
+		}
+		return routes, nil
+	}
+}
+
+// IstioDestinationService resolves a destination host to a kubernetes Service namespace and name.
+// Short names are relative to the VirtualService namespace, `name.namespace` and `name.namespace.svc[.cluster.domain]`
+// are fully qualified, and any other host is outside of the cluster and reported as not ok.
+func IstioDestinationService(host, namespace string) (string, string, bool) {
+	parts := strings.Split(host, ".")
+	switch {
+	case len(parts) == 1:
+		return namespace, parts[0], true
+	case len(parts) == 2:
+		return parts[1], parts[0], true
+	case parts[2] == "svc":
+		return parts[1], parts[0], true
+	}
+	return "", "", false
+}
+
+// istioURLs returns a URL for each gateway server, virtual service host and path combination.
+// Hosts are limited to those the server exposes, which may be wildcards and be prefixed with a namespace.
+func istioURLs(servers []IstioServer, hosts []string, paths []string) []url.URL {
+	urls := []url.URL{}
+	for _, server := range servers {
+		scheme := ""
+		switch strings.ToUpper(server.Port.Protocol) {
+		case "HTTP", "HTTP2", "GRPC":
+			scheme = "http"
+			if server.TLS != nil {
+				scheme = "https"
+			}
+		case "HTTPS":
+			scheme = "https"
+		default:
+			continue
+		}
+
+		for _, host := range hosts {
+			if !istioServerAcceptsHost(server.Hosts, host) {
+				continue
+			}
+			if (scheme == "http" && server.Port.Number != 80) || (scheme == "https" && server.Port.Number != 443) {
+				host = net.JoinHostPort(host, strconv.Itoa(int(server.Port.Number)))
+			}
+			for _, path := range paths {
+				urls = append(urls, url.URL{Scheme: scheme, Host: host, Path: path})
+			}
+		}
+	}
+	return urls
+}
+
+// istioServerAcceptsHost returns true if any of the server hosts match the host
+func istioServerAcceptsHost(serverHosts []string, host string) bool {
+	for _, sh := range serverHosts {
+		// server hosts may be scoped to a namespace, e.g. `prod/*.example.com`
+		if i := strings.Index(sh, "/"); i != -1 {
+			sh = sh[i+1:]
+		}
+		if sh == "*" || sh == host {
+			return true
+		}
+		if strings.HasPrefix(sh, "*.") && strings.HasSuffix(host, sh[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIstioDestinationService(t *testing.T) {
+	tests := []struct {
+		name          string
+		host          string
+		wantNamespace string
+		wantService   string
+		wantOK        bool
+	}{
+		{
+			name:          "Short name - relative to the virtual service namespace",
+			host:          "reviews",
+			wantNamespace: "bookinfo",
+			wantService:   "reviews",
+			wantOK:        true,
+		},
+		{
+			name:          "Namespaced - name.namespace",
+			host:          "ratings.prod",
+			wantNamespace: "prod",
+			wantService:   "ratings",
+			wantOK:        true,
+		},
+		{
+			name:          "FQDN - cluster local service name",
+			host:          "details.prod.svc.cluster.local",
+			wantNamespace: "prod",
+			wantService:   "details",
+			wantOK:        true,
+		},
+		{
+			name:   "External - hosts outside of the cluster are not services",
+			host:   "api.payments.example.com",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, service, ok := IstioDestinationService(tt.host, "bookinfo")
+			if ok != tt.wantOK || namespace != tt.wantNamespace || service != tt.wantService {
+				t.Errorf("IstioDestinationService() = %q, %q, %v, want %q, %q, %v", namespace, service, ok, tt.wantNamespace, tt.wantService, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIstioURLs(t *testing.T) {
+	server := IstioServer{Hosts: []string{"bookinfo/*.example.com"}}
+	server.Port.Number = 443
+	server.Port.Protocol = "HTTPS"
+
+	got := istioURLs([]IstioServer{server}, []string{"shop.example.com", "shop.internal"}, []string{"/productpage"})
+	if len(got) != 1 || got[0].String() != "https://shop.example.com/productpage" {
+		t.Errorf("istioURLs() = %v, want [https://shop.example.com/productpage]", got)
+	}
+}
+
+func TestIstioVirtualServiceResolver(t *testing.T) {
+	gv := IstioGroupVersions[0]
+	public := map[string]interface{}{
+		"apiVersion": gv.String(),
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": "public", "namespace": "istio-system"},
+		"spec": map[string]interface{}{"servers": []interface{}{
+			map[string]interface{}{"port": map[string]interface{}{"number": int64(443), "protocol": "HTTPS", "name": "https"}, "hosts": []interface{}{"*.example.com"}},
+			map[string]interface{}{"port": map[string]interface{}{"number": int64(8080), "protocol": "HTTP", "name": "http"}, "hosts": []interface{}{"default/shop.example.com"}},
+			map[string]interface{}{"port": map[string]interface{}{"number": int64(5432), "protocol": "TCP", "name": "postgres"}, "hosts": []interface{}{"*"}},
+		}},
+	}
+	virtualService := func(name string, gateways []interface{}, http ...interface{}) map[string]interface{} {
+		spec := map[string]interface{}{"hosts": []interface{}{"shop.example.com"}, "http": http}
+		if gateways != nil {
+			spec["gateways"] = gateways
+		}
+		return map[string]interface{}{
+			"apiVersion": gv.String(),
+			"kind":       "VirtualService",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec":       spec,
+		}
+	}
+	destination := func(host, subset string, port, weight int64) map[string]interface{} {
+		d := map[string]interface{}{"host": host, "port": map[string]interface{}{"number": port}}
+		if len(subset) != 0 {
+			d["subset"] = subset
+		}
+		return map[string]interface{}{"destination": d, "weight": weight}
+	}
+
+	tests := []struct {
+		name     string
+		services []map[string]interface{}
+		want     []string
+	}{
+		{
+			name: "Gateway - the servers of a gateway in another namespace accepting the hosts are used, mesh is not a class",
+			services: []map[string]interface{}{
+				virtualService("shop", []interface{}{"istio-system/public", "mesh"}, map[string]interface{}{
+					"match": []interface{}{map[string]interface{}{"uri": map[string]interface{}{"prefix": "/api"}}},
+					"route": []interface{}{destination("api", "", 8080, 100)},
+				}),
+			},
+			want: []string{"shop istio istio-system/public [https://shop.example.com/api http://shop.example.com:8080/api] -> [default/api:8080]"},
+		},
+		{
+			name: "Split traffic - weights and subsets are kept for fully qualified and short hosts",
+			services: []map[string]interface{}{
+				virtualService("reviews", []interface{}{"istio-system/public"}, map[string]interface{}{
+					"route": []interface{}{destination("reviews.bookinfo.svc.cluster.local", "v1", 9080, 80), destination("reviews", "v2", 9080, 20)},
+				}),
+			},
+			want: []string{"reviews istio istio-system/public [https://shop.example.com/ http://shop.example.com:8080/] -> [bookinfo/reviews:9080@v1=80 default/reviews:9080@v2=20]"},
+		},
+		{
+			name: "External destination - hosts outside of the cluster are left out and the split is kept",
+			services: []map[string]interface{}{
+				virtualService("httpbin", []interface{}{"istio-system/public"}, map[string]interface{}{
+					"route": []interface{}{destination("httpbin.example.org", "", 80, 50), destination("httpbin", "", 8000, 50)},
+				}),
+			},
+			want: []string{"httpbin istio istio-system/public [https://shop.example.com/ http://shop.example.com:8080/] -> [default/httpbin:8000=50]"},
+		},
+		{
+			name: "Mesh - virtual services for sidecar traffic only are not entry points",
+			services: []map[string]interface{}{
+				virtualService("explicit", []interface{}{"mesh"}, map[string]interface{}{"route": []interface{}{destination("web", "", 80, 100)}}),
+				virtualService("implicit", nil, map[string]interface{}{"route": []interface{}{destination("web", "", 80, 100)}}),
+			},
+			want: []string{},
+		},
+		{
+			name: "Missing gateway - the route is kept without URLs",
+			services: []map[string]interface{}{
+				virtualService("shop", []interface{}{"private"}, map[string]interface{}{"route": []interface{}{destination("web", "", 80, 100)}}),
+			},
+			want: []string{"shop istio default/private [] -> [default/web:80]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testDynamicClient(t, gv, map[string][]map[string]interface{}{"gateways": {public}, "virtualservices": tt.services})
+			routes, err := IstioVirtualServiceResolver(client, gv)("default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := testRouteSummaries(routes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IstioVirtualServiceResolver() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,18 +4,14 @@ import (
 	"reflect"
 
 	"go.uber.org/zap"
-	v1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
 	// ExternalDNSHostnameAnnotation is the external-dns service's annotaiton denoting the hostname
-	ExternalDNSHostnameAnnotation = "external-dns.alpha.kunernetes.io/hostname"
+	ExternalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
 
 	// KubernetesIngressTLSRedirect annotation indicating enforcement of https redirect
 	// Traefik and some other ingresses read this
@@ -40,76 +36,141 @@ var (
 	}
 )
 
-// DeploymentIngressPath represents the deployment -> ingress path.
+// DeploymentIngressPath represents the workload -> ingress path.
 type DeploymentIngressPath struct {
-	Deployment  v1.Deployment
-	StatefulSet v1.StatefulSet
-	Pods        []apiv1.Pod
-	Services    []apiv1.Service
-	Ingresses   []v1beta1.Ingress
+	// Cluster is the name of the cluster the workload runs in, empty when only one cluster is read
+	Cluster  string
+	Workload Workload
+	Pods     []apiv1.Pod
+	Services []apiv1.Service
+	// Endpoints are the addresses of the workload's services that route to it
+	Endpoints []EndpointAddress
+	Routes    []Route
 }
 
 // DeploymentIngressPaths represents a slice of DeploymentIngressPath structs
 type DeploymentIngressPaths []DeploymentIngressPath
 
-// NewClient returns a new kubernetes.clientset
-func NewClient(masterURL, kubeconfig string) (*kubernetes.Clientset, error) {
-	var config *rest.Config
-	var err error
-	config, err = rest.InClusterConfig()
+// NewClient returns a new kubernetes.clientset and a dynamic client built from the same config.
+// The in-cluster service account is used when running in a pod and masterURL is empty, the kubeconfig otherwise.
+func NewClient(masterURL, kubeconfig string) (*kubernetes.Clientset, dynamic.Interface, error) {
+	config, err := ClusterConfig{Kubeconfig: kubeconfig, Server: masterURL}.RESTConfig()
 	if err != nil {
-		zap.S().Info("could not perform incluster config. falling back to KUBECONFIG")
-		config, err = clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+		zap.S().Error("could not authenticate to cluster\n")
+		return nil, nil, err
 	}
+
+	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		zap.S().Error("could not authenticate to cluster\n")
-		return nil, err
+		return nil, nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
 	}
+	return clientset, dynamicClient, nil
+}
 
-	return kubernetes.NewForConfig(config)
+// GetDeploymentIngressPaths lists the objects in the namespace once and joins them into a DeploymentIngressPath per workload.
+// Objects that cannot be listed are left out and named in the warnings of the result,
+// it only fails when none of the deployments, pods and services can be listed, e.g. when the cluster is unreachable.
+func GetDeploymentIngressPaths(clientset kubernetes.Interface, dynamicClient dynamic.Interface, resolvers []RouteResolver, namespace string) (Result, error) {
+	return getDeploymentIngressPaths(clientset, dynamicClient, resolvers, namespace, listNodesOnce(clientset))
 }
 
-// GetDeploymentIngressPaths ...
-func GetDeploymentIngressPaths(clientset *kubernetes.Clientset, namespace string) (DeploymentIngressPaths, error) {
-	deploymentsClient := clientset.AppsV1().Deployments(namespace)
-	zap.S().Debugf("Listing deployments in namespace %q\n", namespace)
-	dList, err := deploymentsClient.List(metav1.ListOptions{})
+// getDeploymentIngressPaths is GetDeploymentIngressPaths with the nodes listed by listNodes,
+// which is shared by the namespaces of a cluster
+func getDeploymentIngressPaths(clientset kubernetes.Interface, dynamicClient dynamic.Interface, resolvers []RouteResolver, namespace string, listNodes func() ([]apiv1.Node, error)) (Result, error) {
+	result := NewResult()
+	workloads, workloadErrs := ListWorkloads(clientset, namespace)
+	for _, resource := range WorkloadResources {
+		if err, ok := workloadErrs[resource]; ok {
+			result.Warn(namespace, resource, err)
+		}
+	}
+
+	pods, podsErr := ListPods(clientset, namespace)
+	if podsErr != nil {
+		result.Warn(namespace, "pods", podsErr)
+	}
+	workloads = append(workloads, BarePodWorkloads(pods)...)
+
+	services, servicesErr := ListServices(clientset, namespace)
+	if servicesErr != nil {
+		result.Warn(namespace, "services", servicesErr)
+	}
+	if workloadErrs["deployments"] != nil && podsErr != nil && servicesErr != nil {
+		return result, workloadErrs["deployments"]
+	}
+
+	endpoints, err := ListServiceEndpoints(clientset, dynamicClient, namespace)
 	if err != nil {
-		zap.S().Fatalf(err.Error())
+		result.Warn(namespace, "endpoints", err)
 	}
 
-	serviceDeployments, _ := GetServiceDeployments(clientset, namespace)
+	routes, err := ExposedServiceRoutes(services, listNodes)
 	if err != nil {
-		return nil, err
+		result.Warn(namespace, "nodes", err)
+	}
+	apiRoutes, errs := ListRoutes(resolvers, namespace)
+	for _, err := range errs {
+		result.Warn(namespace, RouteErrorResource(err), err)
 	}
+	routes = append(routes, apiRoutes...)
 
-	dips := DeploymentIngressPaths{}
-	for _, deployment := range dList.Items {
-		dip := DeploymentIngressPath{}
-		dip.Deployment = deployment
+	result.Paths = NewDeploymentIngressPaths(workloads, pods, services, endpoints, routes)
+	return result, nil
+}
 
-		zap.S().Debugf("Getting pods associated with deployment %q\n", dip.Deployment.Name)
-		pods, err := DeploymentPods(clientset, dip.Deployment)
-		if err != nil {
-			return nil, err
+// NewDeploymentIngressPaths joins the workloads to their pods, the services that send them traffic and the routes to those services.
+// Selectorless services with addresses outside of the cluster are reported as External workloads.
+func NewDeploymentIngressPaths(workloads []Workload, pods []apiv1.Pod, services []apiv1.Service, endpoints ServiceEndpoints, routes []Route) DeploymentIngressPaths {
+	podIndex := NewPodIndex(pods)
+	workloadPods := map[string][]apiv1.Pod{}
+	for _, workload := range workloads {
+		workloadPods[WorkloadID(workload)] = podIndex.WorkloadPods(workload)
+	}
+
+	serviceWorkloads := GetServiceWorkloads(services, endpoints, workloads, workloadPods)
+
+	workloadServices := map[string][]apiv1.Service{}
+	for _, sw := range serviceWorkloads {
+		for _, w := range sw.Workloads {
+			workloadServices[WorkloadID(w)] = append(workloadServices[WorkloadID(w)], sw.Service)
 		}
-		dip.Pods = pods.Items
+	}
+	routeIndex := NewRouteIndex(routes)
 
-		zap.S().Debugf("Finding the services that select deployment %q", dip.Deployment.Name)
-		for _, sd := range serviceDeployments {
-			if sd.SelectsDeployment(deployment) {
-				dip.Services = append(dip.Services, sd.Service)
-			}
+	dips := DeploymentIngressPaths{}
+	for _, workload := range workloads {
+		dip := DeploymentIngressPath{}
+		dip.Workload = workload
+		dip.Pods = workloadPods[WorkloadID(workload)]
+		dip.Services = workloadServices[WorkloadID(workload)]
+		for _, svc := range dip.Services {
+			dip.Endpoints = append(dip.Endpoints, PodAddresses(endpoints[ServiceKey(svc)], dip.Pods)...)
 		}
 
-		for _, s := range dip.Services {
-			zap.S().Debugf("Finding ingresses that select service %q", s.Name)
-			ingresses, _ := GetServiceIngresses(clientset, s)
-			dip.Ingresses = append(dip.Ingresses, ingresses.Items...)
+		dip.Routes = routeIndex.ServicesRoutes(dip.Services)
+		dips = append(dips, dip)
+	}
+
+	// services without a selector may send traffic to addresses outside of the cluster
+	for _, sw := range serviceWorkloads {
+		external := ExternalAddresses(endpoints[ServiceKey(sw.Service)])
+		if len(sw.Service.Spec.Selector) != 0 || len(external) == 0 {
+			continue
+		}
+		service := sw.Service
+		dip := DeploymentIngressPath{
+			Workload:  ExternalWorkload{Service: &service, Addresses: external},
+			Services:  []apiv1.Service{service},
+			Endpoints: external,
 		}
+		dip.Routes = routeIndex.ServicesRoutes(dip.Services)
 		dips = append(dips, dip)
 	}
-	return dips, nil
+	return dips
 }
 
 // ListContains is a helper for determining if a deployment pointer exists in a <T>List
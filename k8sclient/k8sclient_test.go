@@ -1,9 +1,20 @@
 package k8sclient
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestListContains(t *testing.T) {
@@ -36,3 +47,355 @@ func TestListContains(t *testing.T) {
 		})
 	}
 }
+
+// BenchmarkGetDeploymentIngressPaths measures a namespace of 2000 deployments, each with 3 pods, a service and an ingress
+func BenchmarkGetDeploymentIngressPaths(b *testing.B) {
+	const deployments, podsPerDeployment = 2000, 3
+	controller := true
+
+	objects := []runtime.Object{}
+	ingresses := []runtime.Object{}
+	for d := 0; d < deployments; d++ {
+		name := fmt.Sprintf("app-%d", d)
+		labels := map[string]string{"app": name}
+
+		deployment := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)}}
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+		deployment.Spec.Template.Labels = labels
+		objects = append(objects, deployment)
+
+		for p := 0; p < podsPerDeployment; p++ {
+			pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%d", name, p), Namespace: "default", Labels: labels}}
+			pod.OwnerReferences = []metav1.OwnerReference{{Kind: KindReplicaSet, Name: name, Controller: &controller}}
+			objects = append(objects, pod)
+		}
+
+		service := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		service.Spec.Selector = labels
+		service.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+		objects = append(objects, service)
+
+		ingresses = append(ingresses, testIngress("default", name, name+".example.com", name, int64(80)))
+	}
+
+	clientset := fake.NewSimpleClientset(objects...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), ingresses...)
+	resolvers := []RouteResolver{
+		IngressResolver(dynamicClient, IngressGroupVersions[0].WithResource("ingresses")),
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		result, err := GetDeploymentIngressPaths(clientset, dynamicClient, resolvers, "default")
+		if err != nil {
+			b.Fatal(err)
+		}
+		dips := result.Paths
+		if len(dips) != deployments || len(dips[0].Pods) != podsPerDeployment || len(dips[0].Services) != 1 || len(dips[0].Routes) != 1 {
+			b.Fatalf("got %d paths, the first with %d pods, %d services and %d routes", len(dips), len(dips[0].Pods), len(dips[0].Services), len(dips[0].Routes))
+		}
+	}
+}
+
+// testIngress returns a networking.k8s.io/v1 Ingress routing / on the host to the service port, a number or a name
+func testIngress(namespace, name, host, service string, port interface{}) *unstructured.Unstructured {
+	servicePort := map[string]interface{}{"number": port}
+	if name, ok := port.(string); ok {
+		servicePort = map[string]interface{}{"name": name}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "Ingress",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec": map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{
+					"host": host,
+					"http": map[string]interface{}{
+						"paths": []interface{}{
+							map[string]interface{}{
+								"path":     "/",
+								"pathType": "Prefix",
+								"backend": map[string]interface{}{
+									"service": map[string]interface{}{"name": service, "port": servicePort},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func TestGetDeploymentIngressPaths(t *testing.T) {
+	controller := true
+	webLabels := map[string]string{"app": "web"}
+	dbLabels := map[string]string{"app": "db"}
+
+	web := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web"}}
+	web.Spec.Selector = &metav1.LabelSelector{MatchLabels: webLabels}
+	web.Spec.Template.Labels = webLabels
+	// replicasets owned by the deployment are represented by it
+	webRS := &v1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-5d9", Namespace: "default", UID: "web-5d9"}}
+	webRS.OwnerReferences = []metav1.OwnerReference{{Kind: KindDeployment, Name: "web", Controller: &controller}}
+	webRS.Spec.Selector = web.Spec.Selector
+	webRS.Spec.Template.Labels = webLabels
+	webPod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-5d9-abc", Namespace: "default", UID: "web-5d9-abc", Labels: webLabels}}
+	webPod.OwnerReferences = []metav1.OwnerReference{{Kind: KindReplicaSet, Name: "web-5d9", Controller: &controller}}
+	webPod.Status.PodIP = "10.42.0.5"
+
+	db := &v1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "db"}}
+	db.Spec.Selector = &metav1.LabelSelector{MatchLabels: dbLabels}
+	db.Spec.Template.Labels = dbLabels
+	dbPod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "default", UID: "db-0", Labels: dbLabels}}
+	dbPod.OwnerReferences = []metav1.OwnerReference{{Kind: KindStatefulSet, Name: "db", Controller: &controller}}
+
+	agentLabels := map[string]string{"app": "agent"}
+	agent := &v1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default", UID: "agent"}}
+	agent.Spec.Selector = &metav1.LabelSelector{MatchLabels: agentLabels}
+	agent.Spec.Template.Labels = agentLabels
+	agent.Status.DesiredNumberScheduled = 2
+	agent.Status.NumberReady = 1
+	agentPods := []runtime.Object{}
+	for n, node := range []string{"node-a", "node-b"} {
+		pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "agent-" + node, Namespace: "default", UID: types.UID("agent-" + node), Labels: agentLabels}}
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: KindDaemonSet, Name: "agent", Controller: &controller}}
+		pod.Spec.NodeName = node
+		pod.Status.PodIP = fmt.Sprintf("10.42.%d.7", n+1)
+		agentPods = append(agentPods, pod)
+	}
+
+	debug := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default", UID: "debug"}}
+
+	webSvc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-svc"}}
+	webSvc.Spec.Selector = webLabels
+	webSvc.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+	webSvc.Spec.Type = apiv1.ServiceTypeLoadBalancer
+	webSvc.Status.LoadBalancer.Ingress = []apiv1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+	webEndpoints := &apiv1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	webEndpoints.Subsets = []apiv1.EndpointSubset{{
+		Addresses: []apiv1.EndpointAddress{{IP: "10.42.0.5", TargetRef: &apiv1.ObjectReference{Kind: KindPod, Name: "web-5d9-abc", Namespace: "default"}}},
+	}}
+
+	dbSvc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", UID: "db-svc"}}
+	dbSvc.Spec.Selector = dbLabels
+	dbSvc.Spec.ClusterIP = apiv1.ClusterIPNone
+	dbSvc.Spec.Ports = []apiv1.ServicePort{{Name: "postgres", Port: 5432}}
+
+	aliasSvc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "alias", Namespace: "default", UID: "alias-svc"}}
+	aliasSvc.Spec.Type = apiv1.ServiceTypeExternalName
+	aliasSvc.Spec.ExternalName = "web.example.com"
+
+	legacySvc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default", UID: "legacy-svc"}}
+	legacySvc.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+	legacyEndpoints := &apiv1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"}}
+	legacyEndpoints.Subsets = []apiv1.EndpointSubset{{Addresses: []apiv1.EndpointAddress{{IP: "192.0.2.10"}}}}
+
+	// objects in other namespaces are never joined
+	otherSvc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other", UID: "other-svc"}}
+	otherSvc.Spec.Selector = webLabels
+
+	objects := append([]runtime.Object{web, webRS, webPod, db, dbPod, agent}, agentPods...)
+	clientset := fake.NewSimpleClientset(append(objects, debug, webSvc, webEndpoints, dbSvc, aliasSvc, legacySvc, legacyEndpoints, otherSvc)...)
+	clientset.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses", Namespaced: true, Kind: "Ingress"}}},
+	}
+	tlsIngress := testIngress("default", "web", "web.example.com", "web", "http")
+	tlsIngress.Object["spec"].(map[string]interface{})["tls"] = []interface{}{
+		map[string]interface{}{"hosts": []interface{}{"web.example.com"}},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		tlsIngress,
+		testIngress("default", "legacy", "legacy.example.com", "legacy", int64(80)),
+		// the port does not exist on the service so the ingress does not route to it
+		testIngress("default", "web-admin", "admin.example.com", "web", int64(8080)),
+		testIngress("other", "web", "other.example.com", "web", int64(80)),
+	)
+
+	result, err := GetDeploymentIngressPaths(clientset, dynamicClient, DefaultRouteResolvers(clientset, dynamicClient), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Partial() {
+		t.Errorf("GetDeploymentIngressPaths() warnings = %v, want none", result.Warnings)
+	}
+	dips := result.Paths
+
+	type row struct {
+		kind     string
+		pods     int
+		nodes    []string
+		services []string
+		urls     []string
+	}
+	order := []string{}
+	got := map[string]row{}
+	for _, dip := range dips {
+		order = append(order, dip.Workload.GetName())
+		r := row{kind: dip.Workload.Kind(), pods: len(dip.Pods), nodes: []string{}, services: []string{}, urls: []string{}}
+		for _, p := range dip.Pods {
+			if len(p.Spec.NodeName) != 0 {
+				r.nodes = append(r.nodes, p.Spec.NodeName)
+			}
+		}
+		for _, s := range dip.Services {
+			r.services = append(r.services, s.Name)
+		}
+		for _, route := range dip.Routes {
+			for _, u := range route.URLs {
+				r.urls = append(r.urls, u.String())
+			}
+		}
+		got[dip.Workload.GetName()] = r
+	}
+
+	want := map[string]row{
+		"web":    {kind: KindDeployment, pods: 1, nodes: []string{}, services: []string{"web"}, urls: []string{"http://203.0.113.10:80", "https://web.example.com/"}},
+		"db":     {kind: KindStatefulSet, pods: 1, nodes: []string{}, services: []string{"db"}, urls: []string{}},
+		"agent":  {kind: KindDaemonSet, pods: 2, nodes: []string{"node-a", "node-b"}, services: []string{}, urls: []string{}},
+		"debug":  {kind: KindPod, pods: 1, nodes: []string{}, services: []string{}, urls: []string{}},
+		"legacy": {kind: KindExternal, pods: 0, nodes: []string{}, services: []string{"legacy"}, urls: []string{"http://legacy.example.com/"}},
+	}
+	if wantOrder := []string{"web", "db", "agent", "debug", "legacy"}; !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("GetDeploymentIngressPaths() order = %v, want %v", order, wantOrder)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDeploymentIngressPaths() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// daemonsets report their scheduled pods and name the node of each pod
+	agentPath := dips[2]
+	if replicas := agentPath.Workload.Replicas(); replicas.Desired != 2 || replicas.Ready != 1 {
+		t.Errorf("DaemonSet replicas = %+v, want 1 of 2 ready", replicas)
+	}
+	table := DeploymentIngressPaths{agentPath}.NewTable().Render()
+	for _, cell := range []string{"Ready: 1/2", "10.42.1.7 (node-a)", "10.42.2.7 (node-b)"} {
+		if !strings.Contains(table, cell) {
+			t.Errorf("NewTable() does not contain %q:\n%s", cell, table)
+		}
+	}
+}
+
+func TestGetDeploymentIngressPathsWithoutUIDs(t *testing.T) {
+	// objects created by hand, e.g. in the fake clientset, have no UID
+	deployment := func(name string) *v1.Deployment {
+		d := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"}}
+		d.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}}
+		d.Spec.Template.Labels = map[string]string{"app": name}
+		return d
+	}
+	pod := func(name, app string) *apiv1.Pod {
+		p := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", Labels: map[string]string{"app": app}}}
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: KindReplicaSet, Name: app + "-5d9", Controller: &[]bool{true}[0]}}
+		return p
+	}
+	service := func(name string) *apiv1.Service {
+		svc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"}}
+		svc.Spec.Selector = map[string]string{"app": name}
+		return svc
+	}
+	clientset := fake.NewSimpleClientset(deployment("web"), deployment("cart"), pod("web-1", "web"), pod("cart-1", "cart"), service("web"), service("cart"))
+
+	result, err := GetDeploymentIngressPaths(clientset, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), []RouteResolver{}, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, dip := range result.Paths {
+		for _, p := range dip.Pods {
+			got[dip.Workload.GetName()] = append(got[dip.Workload.GetName()], p.Name)
+		}
+		for _, s := range dip.Services {
+			got[dip.Workload.GetName()] = append(got[dip.Workload.GetName()], "service/"+s.Name)
+		}
+	}
+	want := map[string][]string{"web": {"web-1", "service/web"}, "cart": {"cart-1", "service/cart"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDeploymentIngressPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestGetDeploymentIngressPathsPartial(t *testing.T) {
+	web := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web"}}
+	nodePort := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}}
+	nodePort.Spec.Type = apiv1.ServiceTypeNodePort
+	nodePort.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}}
+	failing := func(resources ...string) *fake.Clientset {
+		clientset := fake.NewSimpleClientset(web, nodePort)
+		for _, resource := range resources {
+			clientset.PrependReactor("list", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, fmt.Errorf("%s are unavailable", action.GetResource().Resource)
+			})
+		}
+		return clientset
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	tests := []struct {
+		name      string
+		clientset *fake.Clientset
+		// discovered resolves the routes of the APIs the clientset serves, it serves none
+		discovered bool
+		workloads  int
+		warnings   []string
+		wantErr    bool
+	}{
+		{
+			name:      "Services - the workloads are still reported",
+			clientset: failing("services", "endpoints"),
+			workloads: 1,
+			warnings:  []string{`namespace "shop": services: services are unavailable`, `namespace "shop": endpoints: endpoints are unavailable`},
+		},
+		{
+			name:      "StatefulSets - the deployments are still reported",
+			clientset: failing("statefulsets", "daemonsets"),
+			workloads: 1,
+			warnings:  []string{`namespace "shop": statefulsets: statefulsets are unavailable`, `namespace "shop": daemonsets: daemonsets are unavailable`},
+		},
+		{
+			name:      "Nodes - the node port routes lack node addresses",
+			clientset: failing("nodes"),
+			workloads: 1,
+			warnings:  []string{`namespace "shop": nodes: nodes are unavailable`},
+		},
+		{
+			name:       "Ingress discovery - the missing ingresses are named",
+			clientset:  failing(),
+			discovered: true,
+			workloads:  1,
+			warnings:   []string{`namespace "shop": ingresses: could not discover the ingress api: the cluster does not serve any known API version of ingresses`},
+		},
+		{
+			name:      "Everything - nothing could be read",
+			clientset: failing("deployments", "pods", "services"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvers := []RouteResolver{}
+			if tt.discovered {
+				resolvers = DefaultRouteResolvers(tt.clientset, dynamicClient)
+			}
+			result, err := GetDeploymentIngressPaths(tt.clientset, dynamicClient, resolvers, "shop")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDeploymentIngressPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(result.Paths) != tt.workloads {
+				t.Errorf("GetDeploymentIngressPaths() got %d paths, want %d", len(result.Paths), tt.workloads)
+			}
+			warnings := []string{}
+			for _, w := range result.Warnings {
+				warnings = append(warnings, w.String())
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("GetDeploymentIngressPaths() warnings = %v, want %v", warnings, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package k8sclient
+
+import (
+	"net/url"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// KindKnativeService is the kind reported for routes built from Knative Services
+	KindKnativeService = "KnativeService"
+)
+
+var (
+	// KnativeServingGroupVersions are the Knative serving group versions, in order of preference
+	KnativeServingGroupVersions = []schema.GroupVersion{
+		{Group: "serving.knative.dev", Version: "v1"},
+	}
+)
+
+// KnativeService is the subset of a Knative Service needed to build routes
+type KnativeService struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Status            struct {
+		URL     string                 `json:"url,omitempty"`
+		Traffic []KnativeTrafficTarget `json:"traffic,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// KnativeTrafficTarget is the share of traffic a Knative Service sends to a revision
+type KnativeTrafficTarget struct {
+	RevisionName string `json:"revisionName,omitempty"`
+	Percent      *int32 `json:"percent,omitempty"`
+	Tag          string `json:"tag,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// KnativeServiceResolver returns a RouteResolver that lists Knative Services
+func KnativeServiceResolver(client dynamic.Interface, gv schema.GroupVersion) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		items, err := ListUnstructured(client.Resource(gv.WithResource("services")).Namespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+
+		routes := []Route{}
+		for _, item := range items {
+			ks := KnativeService{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &ks); err != nil {
+				return nil, err
+			}
+			routes = append(routes, ks.Routes()...)
+		}
+		return routes, nil
+	}
+}
+
+// Routes converts the Knative Service into a Route for its URL and one for each tagged revision
+func (ks KnativeService) Routes() []Route {
+	main := Route{
+		Kind:      KindKnativeService,
+		Name:      ks.Name,
+		Namespace: ks.Namespace,
+		Class:     "knative",
+	}
+	if u, err := url.Parse(ks.Status.URL); err == nil && len(ks.Status.URL) != 0 {
+		main.URLs = append(main.URLs, *u)
+		if u.Scheme == "https" {
+			main.TLSTermination = "edge"
+		}
+	}
+
+	routes := []Route{}
+	for _, target := range ks.Status.Traffic {
+		if len(target.RevisionName) == 0 {
+			continue
+		}
+		backends := knativeRevisionBackends(target.RevisionName, target.Percent)
+		if target.Percent != nil && *target.Percent != 0 {
+			main.Backends = append(main.Backends, backends...)
+		}
+
+		// tagged revisions are reachable on their own url regardless of their share of traffic
+		if len(target.Tag) == 0 {
+			continue
+		}
+		tagged := Route{
+			Kind:      KindKnativeService,
+			Name:      ks.Name + "@" + target.Tag,
+			Namespace: ks.Namespace,
+			Class:     "knative",
+			Backends:  knativeRevisionBackends(target.RevisionName, nil),
+		}
+		if u, err := url.Parse(target.URL); err == nil && len(target.URL) != 0 {
+			tagged.URLs = append(tagged.URLs, *u)
+		}
+		routes = append(routes, tagged)
+	}
+	// a single revision receiving all traffic is not a split
+	if len(main.Backends) == 2 {
+		main.Backends[0].Weight = nil
+		main.Backends[1].Weight = nil
+	}
+
+	return append([]Route{main}, routes...)
+}
+
+// knativeRevisionBackends returns the services knative creates for a revision.
+// The public service has no selector, it is the private service that selects the revision's deployment.
+func knativeRevisionBackends(revisionName string, weight *int32) []RouteBackend {
+	return []RouteBackend{
+		{ServiceName: revisionName, Weight: weight},
+		{ServiceName: revisionName + "-private", Weight: weight},
+	}
+}
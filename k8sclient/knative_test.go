@@ -0,0 +1,84 @@
+package k8sclient
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestKnativeServiceRoutes(t *testing.T) {
+	percent := func(p int32) *int32 { return &p }
+
+	tests := []struct {
+		name    string
+		url     string
+		traffic []KnativeTrafficTarget
+		want    []string
+	}{
+		{
+			name:    "Single revision - no split, the weight is cleared",
+			url:     "https://shop.default.example.com",
+			traffic: []KnativeTrafficTarget{{RevisionName: "shop-00002", Percent: percent(100)}},
+			want:    []string{"shop https://shop.default.example.com edge [shop-00002 shop-00002-private]"},
+		},
+		{
+			name: "Split - both services of each revision carry its weight",
+			url:  "http://shop.default.example.com",
+			traffic: []KnativeTrafficTarget{
+				{RevisionName: "shop-00002", Percent: percent(90)},
+				{RevisionName: "shop-00001", Percent: percent(10)},
+			},
+			want: []string{"shop http://shop.default.example.com  [shop-00002:90 shop-00002-private:90 shop-00001:10 shop-00001-private:10]"},
+		},
+		{
+			name: "Tagged - a route per tag even without traffic, zero percent targets are left out of the main route",
+			url:  "https://shop.default.example.com",
+			traffic: []KnativeTrafficTarget{
+				{RevisionName: "shop-00002", Percent: percent(100)},
+				{RevisionName: "shop-00003", Percent: percent(0), Tag: "canary", URL: "https://canary-shop.default.example.com"},
+			},
+			want: []string{
+				"shop https://shop.default.example.com edge [shop-00002 shop-00002-private]",
+				"shop@canary https://canary-shop.default.example.com  [shop-00003 shop-00003-private]",
+			},
+		},
+		{
+			name:    "Not ready - no url and no revision",
+			traffic: []KnativeTrafficTarget{{Percent: percent(100)}},
+			want:    []string{"shop   []"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := KnativeService{}
+			ks.Name = "shop"
+			ks.Namespace = "default"
+			ks.Status.URL = tt.url
+			ks.Status.Traffic = tt.traffic
+
+			got := []string{}
+			for _, r := range ks.Routes() {
+				if r.Kind != KindKnativeService || r.Namespace != "default" {
+					t.Errorf("Routes() route %+v, want a KnativeService in default", r)
+				}
+				urls := ""
+				for _, u := range r.URLs {
+					urls += u.String()
+				}
+				backends := []string{}
+				for _, b := range r.Backends {
+					if b.Weight != nil {
+						backends = append(backends, fmt.Sprintf("%s:%d", b.ServiceName, *b.Weight))
+					} else {
+						backends = append(backends, b.ServiceName)
+					}
+				}
+				got = append(got, fmt.Sprintf("%s %s %s %v", r.Name, urls, r.TLSTermination, backends))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Routes() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package k8sclient
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// ListPageSize is how many objects are requested per page when listing
+	ListPageSize = 500
+)
+
+// listPages calls list with ListPageSize until the server returns a page without a continue token.
+// list is responsible for keeping the items of each page.
+func listPages(list func(opts metav1.ListOptions) (metav1.ListInterface, error)) error {
+	opts := metav1.ListOptions{Limit: ListPageSize}
+	for {
+		page, err := list(opts)
+		if err != nil {
+			return err
+		}
+		opts.Continue = page.GetContinue()
+		if len(opts.Continue) == 0 {
+			return nil
+		}
+	}
+}
+
+// ListUnstructured returns every object of the resource, following pages
+func ListUnstructured(client dynamic.ResourceInterface) ([]unstructured.Unstructured, error) {
+	items := []unstructured.Unstructured{}
+	err := listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := client.List(opts)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		return page, nil
+	})
+	return items, err
+}
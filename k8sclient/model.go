@@ -0,0 +1,302 @@
+package k8sclient
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Model is an in-memory copy of the objects peruse reads, kept up to date by watches.
+// DeploymentIngressPaths are computed from it without calling the API server.
+type Model struct {
+	namespace string
+	factory   informers.SharedInformerFactory
+	dynamic   *informerDynamicClient
+	// endpointSlices is the EndpointSlice resource, nil when the cluster only serves Endpoints
+	endpointSlices *schema.GroupVersionResource
+	// synced reports whether the typed informer of each resource has listed its objects
+	synced map[string]cache.InformerSynced
+	// nodes is whether nodes are watched, identities limited to namespaces may not list them
+	nodes bool
+}
+
+// ModelAPIs is what a Model needs to know about its cluster before watching it. It is the same for every namespace
+// of a cluster, DiscoverModelAPIs once and share it between the models of the namespaces with NewModelWithAPIs.
+type ModelAPIs struct {
+	// EndpointSlices is the EndpointSlice resource, nil when the cluster only serves Endpoints
+	EndpointSlices *schema.GroupVersionResource
+	// Nodes is whether nodes may be listed, identities limited to namespaces may not
+	Nodes bool
+}
+
+// DiscoverModelAPIs asks the cluster which EndpointSlice API it serves and whether nodes may be listed
+func DiscoverModelAPIs(clientset kubernetes.Interface, client discovery.DiscoveryInterface) ModelAPIs {
+	apis := ModelAPIs{}
+	// node ports are only resolved when nodes may be listed, the authorization API not being served is no reason to skip them
+	if allowed, err := CanList(clientset, "", schema.GroupResource{Resource: "nodes"}); err != nil || allowed {
+		apis.Nodes = true
+	}
+	if gv, err := PreferredGroupVersion(client, EndpointSliceGroupVersions, "endpointslices"); err == nil {
+		resource := gv.WithResource("endpointslices")
+		apis.EndpointSlices = &resource
+	} else {
+		zap.S().Debugf("endpointslices are not served, falling back to endpoints: %s", err.Error())
+	}
+	return apis
+}
+
+// NewModel returns a Model of the namespace, or of every namespace when it is empty.
+// Informers resync every resync period, watches keep the model current in between.
+func NewModel(clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, resync time.Duration) *Model {
+	return NewModelWithAPIs(clientset, dynamicClient, namespace, resync, DiscoverModelAPIs(clientset, clientset.Discovery()))
+}
+
+// NewModelWithAPIs is NewModel with the APIs of the cluster already discovered
+func NewModelWithAPIs(clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, resync time.Duration, apis ModelAPIs) *Model {
+	m := &Model{
+		namespace:      namespace,
+		factory:        informers.NewSharedInformerFactoryWithOptions(clientset, resync, informers.WithNamespace(namespace)),
+		dynamic:        newInformerDynamicClient(dynamicClient, namespace, resync),
+		endpointSlices: apis.EndpointSlices,
+		nodes:          apis.Nodes,
+	}
+
+	// informers are registered up front so Start runs them all
+	m.synced = map[string]cache.InformerSynced{
+		"deployments":  m.factory.Apps().V1().Deployments().Informer().HasSynced,
+		"statefulsets": m.factory.Apps().V1().StatefulSets().Informer().HasSynced,
+		"daemonsets":   m.factory.Apps().V1().DaemonSets().Informer().HasSynced,
+		"replicasets":  m.factory.Apps().V1().ReplicaSets().Informer().HasSynced,
+		"pods":         m.factory.Core().V1().Pods().Informer().HasSynced,
+		"services":     m.factory.Core().V1().Services().Informer().HasSynced,
+	}
+	if m.nodes {
+		m.synced["nodes"] = m.factory.Core().V1().Nodes().Informer().HasSynced
+	}
+	if m.endpointSlices == nil {
+		m.synced["endpoints"] = m.factory.Core().V1().Endpoints().Informer().HasSynced
+	}
+	return m
+}
+
+// DynamicClient returns a dynamic client whose List and Get are answered by the model's informers.
+// Route resolvers built with it read routes from memory.
+func (m *Model) DynamicClient() dynamic.Interface {
+	return m.dynamic
+}
+
+// Start runs the model's informers until stopCh is closed
+func (m *Model) Start(stopCh <-chan struct{}) {
+	m.factory.Start(stopCh)
+	m.dynamic.start(stopCh)
+}
+
+// WaitForCacheSync blocks until every informer has listed its objects, returning false if any failed to
+func (m *Model) WaitForCacheSync(stopCh <-chan struct{}) bool {
+	synced := true
+	for informerType, ok := range m.factory.WaitForCacheSync(stopCh) {
+		if !ok {
+			zap.S().Errorf("the informer for %s has not synced", informerType.String())
+			synced = false
+		}
+	}
+	if m.endpointSlices != nil {
+		if _, err := m.dynamic.lister(*m.endpointSlices); err != nil {
+			zap.S().Errorf(err.Error())
+			synced = false
+		}
+	}
+	return synced
+}
+
+// HasSynced is whether every typed informer, e.g. of workloads, pods and services, has listed its objects.
+// Until then the model is missing objects, e.g. because the cluster is unreachable.
+func (m *Model) HasSynced() bool {
+	for _, synced := range m.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// DeploymentIngressPaths computes the DeploymentIngressPath of every workload in the model.
+// Resources whose informers have not synced, e.g. because they may not be listed, are named in the warnings of the result.
+// It only fails when none of the workload, pod and service informers have synced, e.g. when the cluster is unreachable.
+func (m *Model) DeploymentIngressPaths(resolvers []RouteResolver) (Result, error) {
+	result := NewResult()
+	resources := []string{}
+	for resource := range m.synced {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		if !m.synced[resource]() {
+			result.Warn(m.namespace, resource, fmt.Errorf("has not been listed yet, it may be forbidden or the cluster unreachable"))
+		}
+	}
+	if !m.synced["deployments"]() && !m.synced["pods"]() && !m.synced["services"]() {
+		return result, fmt.Errorf("the model has not been filled yet, the cluster may be unreachable")
+	}
+
+	workloads, err := m.workloads()
+	if err != nil {
+		result.Warn(m.namespace, "workloads", err)
+	}
+	pods, err := m.pods()
+	if err != nil {
+		result.Warn(m.namespace, "pods", err)
+	}
+	workloads = append(workloads, BarePodWorkloads(pods)...)
+	services, err := m.services(m.namespace)
+	if err != nil {
+		result.Warn(m.namespace, "services", err)
+	}
+	endpoints, err := m.serviceEndpoints()
+	if err != nil {
+		result.Warn(m.namespace, "endpoints", err)
+	}
+	routes, err := ExposedServiceRoutes(services, m.listNodes)
+	if err != nil {
+		result.Warn(m.namespace, "nodes", err)
+	}
+	apiRoutes, errs := ListRoutes(resolvers, m.namespace)
+	for _, err := range errs {
+		result.Warn(m.namespace, RouteErrorResource(err), err)
+	}
+	routes = append(routes, apiRoutes...)
+
+	result.Paths = NewDeploymentIngressPaths(workloads, pods, services, endpoints, routes)
+	return result, nil
+}
+
+// workloads returns the controller workloads in the model, ordered by kind then namespace and name like ListWorkloads
+func (m *Model) workloads() ([]Workload, error) {
+	workloads := []Workload{}
+	all := labels.Everything()
+
+	deployments, err := m.factory.Apps().V1().Deployments().Lister().Deployments(m.namespace).List(all)
+	if err != nil {
+		return nil, err
+	}
+	kind := []Workload{}
+	for _, d := range deployments {
+		kind = append(kind, DeploymentWorkload{d.DeepCopy()})
+	}
+	workloads = append(workloads, sortWorkloads(kind)...)
+
+	statefulSets, err := m.factory.Apps().V1().StatefulSets().Lister().StatefulSets(m.namespace).List(all)
+	if err != nil {
+		return nil, err
+	}
+	kind = []Workload{}
+	for _, ss := range statefulSets {
+		kind = append(kind, StatefulSetWorkload{ss.DeepCopy()})
+	}
+	workloads = append(workloads, sortWorkloads(kind)...)
+
+	daemonSets, err := m.factory.Apps().V1().DaemonSets().Lister().DaemonSets(m.namespace).List(all)
+	if err != nil {
+		return nil, err
+	}
+	kind = []Workload{}
+	for _, ds := range daemonSets {
+		kind = append(kind, DaemonSetWorkload{ds.DeepCopy()})
+	}
+	workloads = append(workloads, sortWorkloads(kind)...)
+
+	replicaSets, err := m.factory.Apps().V1().ReplicaSets().Lister().ReplicaSets(m.namespace).List(all)
+	if err != nil {
+		return nil, err
+	}
+	kind = []Workload{}
+	for _, rs := range replicaSets {
+		// replicasets managed by a deployment are already represented by that deployment
+		if metav1.GetControllerOf(rs) != nil {
+			continue
+		}
+		kind = append(kind, ReplicaSetWorkload{rs.DeepCopy()})
+	}
+	workloads = append(workloads, sortWorkloads(kind)...)
+
+	return workloads, nil
+}
+
+func (m *Model) pods() ([]apiv1.Pod, error) {
+	list, err := m.factory.Core().V1().Pods().Lister().Pods(m.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	pods := []apiv1.Pod{}
+	for _, p := range list {
+		pods = append(pods, *p.DeepCopy())
+	}
+	sort.Slice(pods, func(i, j int) bool { return objectKey(&pods[i]) < objectKey(&pods[j]) })
+	return pods, nil
+}
+
+func (m *Model) services(namespace string) ([]apiv1.Service, error) {
+	list, err := m.factory.Core().V1().Services().Lister().Services(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	services := []apiv1.Service{}
+	for _, s := range list {
+		services = append(services, *s.DeepCopy())
+	}
+	sort.Slice(services, func(i, j int) bool { return objectKey(&services[i]) < objectKey(&services[j]) })
+	return services, nil
+}
+
+func (m *Model) listNodes() ([]apiv1.Node, error) {
+	if !m.nodes {
+		return nil, fmt.Errorf("listing nodes is forbidden")
+	}
+	list, err := m.factory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodes := []apiv1.Node{}
+	for _, n := range list {
+		nodes = append(nodes, *n.DeepCopy())
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+func (m *Model) serviceEndpoints() (ServiceEndpoints, error) {
+	if m.endpointSlices != nil {
+		list, err := m.dynamic.list(*m.endpointSlices, m.namespace, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return EndpointSliceServiceEndpoints(list.Items)
+	}
+
+	list, err := m.factory.Core().V1().Endpoints().Lister().Endpoints(m.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	endpoints := []apiv1.Endpoints{}
+	for _, ep := range list {
+		endpoints = append(endpoints, *ep)
+	}
+	return EndpointsServiceEndpoints(endpoints), nil
+}
+
+// sortWorkloads orders workloads by namespace and name as the caches are unordered
+func sortWorkloads(workloads []Workload) []Workload {
+	sort.Slice(workloads, func(i, j int) bool { return objectKey(workloads[i]) < objectKey(workloads[j]) })
+	return workloads
+}
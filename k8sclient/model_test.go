@@ -0,0 +1,103 @@
+package k8sclient
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestModelDeploymentIngressPaths(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	deployment := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"}}
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+	deployment.Spec.Template.Labels = labels
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", Labels: labels}}
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: KindReplicaSet, Name: "web-abc", Controller: &[]bool{true}[0]}}
+	service := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	service.Spec.Selector = labels
+	service.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+
+	ingress := testIngress("default", "web", "web.example.com", "web", int64(80))
+
+	clientset := fake.NewSimpleClientset(deployment, pod, service)
+	model := NewModel(clientset, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), ingress), "default", 0)
+	resolvers := []RouteResolver{
+		IngressResolver(model.DynamicClient(), IngressGroupVersions[0].WithResource("ingresses")),
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	model.Start(stopCh)
+	if !model.WaitForCacheSync(stopCh) {
+		t.Fatal("WaitForCacheSync() = false, want true")
+	}
+
+	result, err := model.DeploymentIngressPaths(resolvers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dips := result.Paths
+	if len(dips) != 1 {
+		t.Fatalf("DeploymentIngressPaths() got %d paths, want 1", len(dips))
+	}
+	dip := dips[0]
+	if dip.Workload.GetName() != "web" || len(dip.Pods) != 1 || len(dip.Services) != 1 {
+		t.Errorf("DeploymentIngressPaths() = %+v, want web with its pod and service", dip)
+	}
+	if len(dip.Routes) != 1 || dip.Routes[0].URLs[0].String() != "http://web.example.com/" {
+		t.Errorf("DeploymentIngressPaths() routes = %+v, want the web ingress", dip.Routes)
+	}
+
+	// objects created after the model synced are picked up by its watches
+	canary := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web-canary", Namespace: "default"}}
+	canary.Spec.Selector = labels
+	if _, err := clientset.CoreV1().Services("default").Create(canary); err != nil {
+		t.Fatal(err)
+	}
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		result, err := model.DeploymentIngressPaths(resolvers)
+		return err == nil && len(result.Paths) == 1 && len(result.Paths[0].Services) == 2, err
+	})
+	if err != nil {
+		t.Errorf("the model did not observe the new service: %s", err.Error())
+	}
+}
+
+func TestModelDynamicClientOtherNamespace(t *testing.T) {
+	gateways := schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	gateway := func(namespace string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "Gateway",
+			"metadata":   map[string]interface{}{"name": "shared", "namespace": namespace},
+		}}
+	}
+	// the fake guesses the wrong resource for the Gateway kind, so they are created with the right one
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	for _, namespace := range []string{"shop", "infra"} {
+		if _, err := client.Resource(gateways).Namespace(namespace).Create(gateway(namespace), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	model := NewModel(fake.NewSimpleClientset(), client, "shop", 0)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	model.Start(stopCh)
+
+	// routes of a namespaced model refer to gateways in shared namespaces its informers do not watch
+	for _, namespace := range []string{"shop", "infra"} {
+		obj, err := model.DynamicClient().Resource(gateways).Namespace(namespace).Get("shared", metav1.GetOptions{})
+		if err != nil || obj.GetNamespace() != namespace {
+			t.Errorf("Get() in namespace %q = %v, %v, want the gateway", namespace, obj, err)
+		}
+	}
+}
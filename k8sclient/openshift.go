@@ -0,0 +1,126 @@
+package k8sclient
+
+import (
+	"net/url"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// KindOpenShiftRoute is the kind reported for routes built from OpenShift Routes
+	KindOpenShiftRoute = "Route"
+)
+
+var (
+	// OpenShiftRouteGroupVersions are the OpenShift route group versions, in order of preference
+	OpenShiftRouteGroupVersions = []schema.GroupVersion{
+		{Group: "route.openshift.io", Version: "v1"},
+	}
+)
+
+// OpenShiftRoute is the subset of an OpenShift Route needed to build routes
+type OpenShiftRoute struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Host              string                   `json:"host,omitempty"`
+		Path              string                   `json:"path,omitempty"`
+		To                OpenShiftRouteTarget     `json:"to"`
+		AlternateBackends []OpenShiftRouteTarget   `json:"alternateBackends,omitempty"`
+		Port              *OpenShiftRoutePort      `json:"port,omitempty"`
+		TLS               *OpenShiftRouteTLSConfig `json:"tls,omitempty"`
+	} `json:"spec,omitempty"`
+	Status struct {
+		Ingress []struct {
+			Host       string `json:"host,omitempty"`
+			RouterName string `json:"routerName,omitempty"`
+		} `json:"ingress,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+// OpenShiftRouteTarget is a weighted service an OpenShift Route forwards to
+type OpenShiftRouteTarget struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// OpenShiftRoutePort is the port an OpenShift Route forwards to, a number is the port of the pods and a name the name of the service port
+type OpenShiftRoutePort struct {
+	TargetPort intstr.IntOrString `json:"targetPort"`
+}
+
+// OpenShiftRouteTLSConfig is the TLS termination of an OpenShift Route
+type OpenShiftRouteTLSConfig struct {
+	Termination string `json:"termination"`
+}
+
+// OpenShiftRouteResolver returns a RouteResolver that lists OpenShift Routes
+func OpenShiftRouteResolver(client dynamic.Interface, gv schema.GroupVersion) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		items, err := ListUnstructured(client.Resource(gv.WithResource("routes")).Namespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+
+		routes := []Route{}
+		for _, item := range items {
+			or := OpenShiftRoute{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &or); err != nil {
+				return nil, err
+			}
+			routes = append(routes, or.Route())
+		}
+		return routes, nil
+	}
+}
+
+// Route converts the OpenShift Route into a Route
+func (or OpenShiftRoute) Route() Route {
+	route := Route{
+		Kind:      KindOpenShiftRoute,
+		Name:      or.Name,
+		Namespace: or.Namespace,
+	}
+
+	routers := []string{}
+	for _, ing := range or.Status.Ingress {
+		routers = append(routers, ing.RouterName)
+	}
+	route.Class = strings.Join(routers, ",")
+
+	u := url.URL{Scheme: "http", Host: or.Spec.Host, Path: or.Spec.Path}
+	if len(u.Host) == 0 && len(or.Status.Ingress) != 0 {
+		u.Host = or.Status.Ingress[0].Host
+	}
+	if len(u.Path) == 0 {
+		u.Path = "/"
+	}
+	if or.Spec.TLS != nil {
+		u.Scheme = "https"
+		route.TLSTermination = strings.ToLower(or.Spec.TLS.Termination)
+	}
+	route.URLs = []url.URL{u}
+
+	for _, target := range append([]OpenShiftRouteTarget{or.Spec.To}, or.Spec.AlternateBackends...) {
+		if target.Kind != "Service" {
+			continue
+		}
+		backend := RouteBackend{ServiceName: target.Name, Weight: target.Weight}
+		// a number is the port of the pods, a name the name of the service port
+		if or.Spec.Port != nil && or.Spec.Port.TargetPort.Type == intstr.Int {
+			backend.TargetPort = or.Spec.Port.TargetPort
+		} else if or.Spec.Port != nil {
+			backend.ServicePort = or.Spec.Port.TargetPort
+		}
+		route.Backends = append(route.Backends, backend)
+	}
+	if len(route.Backends) == 1 {
+		route.Backends[0].Weight = nil
+	}
+	return route
+}
@@ -0,0 +1,65 @@
+package k8sclient
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestOpenShiftRouteRoute(t *testing.T) {
+	ninety, ten := int32(90), int32(10)
+	or := OpenShiftRoute{}
+	or.Name = "frontend"
+	or.Namespace = "shop"
+	or.Spec.Host = "shop.apps.example.com"
+	or.Spec.To = OpenShiftRouteTarget{Kind: "Service", Name: "frontend", Weight: &ninety}
+	or.Spec.AlternateBackends = []OpenShiftRouteTarget{{Kind: "Service", Name: "frontend-canary", Weight: &ten}}
+	or.Spec.TLS = &OpenShiftRouteTLSConfig{Termination: "Edge"}
+
+	route := or.Route()
+	if len(route.URLs) != 1 || route.URLs[0].String() != "https://shop.apps.example.com/" {
+		t.Errorf("Route() URLs = %v, want [https://shop.apps.example.com/]", route.URLs)
+	}
+	if route.TLSTermination != "edge" {
+		t.Errorf("Route() TLSTermination = %q, want %q", route.TLSTermination, "edge")
+	}
+	if len(route.Backends) != 2 || *route.Backends[0].Weight != 90 || route.Backends[1].ServiceName != "frontend-canary" {
+		t.Errorf("Route() Backends = %+v, want frontend at 90 and frontend-canary", route.Backends)
+	}
+}
+
+func TestOpenShiftRouteServiceBackends(t *testing.T) {
+	service := apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "shop"}}
+	service.Spec.Ports = []apiv1.ServicePort{
+		{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+		{Name: "metrics", Port: 9090},
+	}
+
+	tests := []struct {
+		name string
+		port *OpenShiftRoutePort
+		want bool
+	}{
+		{name: "No port - any port of the service", want: true},
+		{name: "Number - the targetPort of a service port", port: &OpenShiftRoutePort{TargetPort: intstr.FromInt(8080)}, want: true},
+		{name: "Number - the service port itself is not the pods' port", port: &OpenShiftRoutePort{TargetPort: intstr.FromInt(80)}, want: false},
+		{name: "Number - an unset targetPort is the service port", port: &OpenShiftRoutePort{TargetPort: intstr.FromInt(9090)}, want: true},
+		{name: "Name - the name of a service port", port: &OpenShiftRoutePort{TargetPort: intstr.FromString("http")}, want: true},
+		{name: "Name - unknown", port: &OpenShiftRoutePort{TargetPort: intstr.FromString("grpc")}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			or := OpenShiftRoute{}
+			or.Name = "frontend"
+			or.Namespace = "shop"
+			or.Spec.To = OpenShiftRouteTarget{Kind: "Service", Name: "frontend"}
+			or.Spec.Port = tt.port
+			if got := len(or.Route().ServiceBackends(service)) != 0; got != tt.want {
+				t.Errorf("ServiceBackends() matched = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package k8sclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Warning names something that could not be read, the result it belongs to is missing it
+type Warning struct {
+	Cluster   string
+	Namespace string
+	// Resource is what could not be read, e.g. deployments or routes, empty when the whole cluster or namespace is missing
+	Resource string
+	Message  string
+}
+
+func (w Warning) String() string {
+	parts := []string{}
+	if len(w.Cluster) != 0 {
+		parts = append(parts, fmt.Sprintf("cluster %q", w.Cluster))
+	}
+	if len(w.Namespace) != 0 {
+		parts = append(parts, fmt.Sprintf("namespace %q", w.Namespace))
+	}
+	if len(w.Resource) != 0 {
+		parts = append(parts, w.Resource)
+	}
+	return strings.Join(append(parts, w.Message), ": ")
+}
+
+// Result is the paths that could be read and warnings naming what could not.
+// The paths are partial when there are warnings.
+type Result struct {
+	Paths    DeploymentIngressPaths
+	Warnings []Warning
+}
+
+// NewResult returns an empty Result
+func NewResult() Result {
+	return Result{Paths: DeploymentIngressPaths{}, Warnings: []Warning{}}
+}
+
+// Add appends the paths and warnings of the other result
+func (r *Result) Add(other Result) {
+	r.Paths = append(r.Paths, other.Paths...)
+	r.Warnings = append(r.Warnings, other.Warnings...)
+}
+
+// Warn records that the resource of the namespace could not be read
+func (r *Result) Warn(namespace, resource string, err error) {
+	r.Warnings = append(r.Warnings, Warning{Namespace: namespace, Resource: resource, Message: err.Error()})
+}
+
+// Partial is whether something could not be read
+func (r Result) Partial() bool {
+	return len(r.Warnings) != 0
+}
+
+// FailedClusters returns the clusters that could not be read at all
+func (r Result) FailedClusters() []string {
+	failed := []string{}
+	for _, w := range r.Warnings {
+		if len(w.Namespace) == 0 && len(w.Resource) == 0 {
+			failed = append(failed, w.Cluster)
+		}
+	}
+	return failed
+}
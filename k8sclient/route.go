@@ -0,0 +1,288 @@
+package k8sclient
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"go.uber.org/zap"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// KindIngress is the kind reported for routes built from Ingresses
+	KindIngress = "Ingress"
+)
+
+// Route is an external entry point and the services it forwards traffic to
+type Route struct {
+	Kind      string
+	Name      string
+	Namespace string
+	// Class is the controller or gateway responsible for the route
+	Class string
+	// TLSTermination is where TLS is terminated, e.g. edge, passthrough or reencrypt, when it is known
+	TLSTermination string
+	// PathType is how the URL paths are matched, e.g. Prefix, Exact or ImplementationSpecific, when it is known
+	PathType string
+	// DefaultBackend is true for catch-all routes that receive traffic no other rule matched
+	DefaultBackend bool
+	URLs           []url.URL
+	Backends       []RouteBackend
+}
+
+// RouteBackend is a service that a Route forwards traffic to
+type RouteBackend struct {
+	// Namespace defaults to the namespace of the Route when empty
+	Namespace   string
+	ServiceName string
+	// ServicePort matches any port of the service when it is the zero value
+	ServicePort intstr.IntOrString
+	// TargetPort matches the service ports whose targetPort is this number, as OpenShift Routes name the port of the pods
+	TargetPort intstr.IntOrString
+	// Weight is the relative share of the route's traffic, nil when the route does not split traffic
+	Weight *int32
+	// Subset is the named subset of the service's pods, as used by Istio DestinationRules
+	Subset string
+}
+
+// RouteResolver lists the routes in a namespace
+type RouteResolver func(namespace string) ([]Route, error)
+
+// ResourceError is the error of a resolver that could not read a resource, e.g. ingresses, instead of its routes
+type ResourceError struct {
+	Resource string
+	Err      error
+}
+
+func (e ResourceError) Error() string {
+	return e.Err.Error()
+}
+
+// RouteErrorResource is the resource to name in the warning of an error returned by a resolver, routes unless it is a ResourceError
+func RouteErrorResource(err error) string {
+	if e, ok := err.(ResourceError); ok {
+		return e.Resource
+	}
+	return "routes"
+}
+
+// failedResolver returns a resolver failing with err in every namespace, so a route API that could not be discovered
+// is named in the warnings of every result instead of its routes silently missing
+func failedResolver(err error) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		return nil, err
+	}
+}
+
+// DefaultRouteResolvers returns a resolver for every supported route API the cluster serves.
+// The routes of externally exposed services are built from the listed services rather than by a resolver.
+func DefaultRouteResolvers(clientset kubernetes.Interface, dynamicClient dynamic.Interface) []RouteResolver {
+	return APIRouteResolvers(clientset.Discovery(), dynamicClient)
+}
+
+// APIRouteResolvers returns a resolver for every supported route API the cluster serves, read through the dynamic client
+func APIRouteResolvers(client discovery.DiscoveryInterface, dynamicClient dynamic.Interface) []RouteResolver {
+	resolvers := []RouteResolver{}
+
+	if resource, err := IngressResource(client); err == nil {
+		resolvers = append(resolvers, IngressResolver(dynamicClient, resource))
+	} else {
+		// every cluster serves ingresses, this is an error talking to it rather than the API not being served
+		zap.S().Errorf("could not discover the ingress api: %s", err.Error())
+		resolvers = append(resolvers, failedResolver(ResourceError{Resource: "ingresses", Err: fmt.Errorf("could not discover the ingress api: %s", err.Error())}))
+	}
+
+	if gv, err := PreferredGroupVersion(client, GatewayGroupVersions, "httproutes"); err == nil {
+		resolvers = append(resolvers, HTTPRouteResolver(dynamicClient, gv))
+	} else {
+		zap.S().Debugf("gateway api is not served: %s", err.Error())
+	}
+
+	if gv, err := PreferredGroupVersion(client, TraefikGroupVersions, "ingressroutes"); err == nil {
+		resolvers = append(resolvers, TraefikIngressRouteResolver(dynamicClient, gv))
+	} else {
+		zap.S().Debugf("traefik crds are not served: %s", err.Error())
+	}
+
+	if gv, err := PreferredGroupVersion(client, IstioGroupVersions, "virtualservices"); err == nil {
+		resolvers = append(resolvers, IstioVirtualServiceResolver(dynamicClient, gv))
+	} else {
+		zap.S().Debugf("istio crds are not served: %s", err.Error())
+	}
+
+	if gv, err := PreferredGroupVersion(client, OpenShiftRouteGroupVersions, "routes"); err == nil {
+		resolvers = append(resolvers, OpenShiftRouteResolver(dynamicClient, gv))
+	} else {
+		zap.S().Debugf("openshift routes are not served: %s", err.Error())
+	}
+
+	if gv, err := PreferredGroupVersion(client, KnativeServingGroupVersions, "services"); err == nil {
+		resolvers = append(resolvers, KnativeServiceResolver(dynamicClient, gv))
+	} else {
+		zap.S().Debugf("knative serving is not served: %s", err.Error())
+	}
+
+	return resolvers
+}
+
+// ListRoutes collects the routes from every resolver, skipping and returning the errors of resolvers that fail
+func ListRoutes(resolvers []RouteResolver, namespace string) ([]Route, []error) {
+	result := []Route{}
+	errs := []error{}
+	for _, resolve := range resolvers {
+		routes, err := resolve(namespace)
+		if err != nil {
+			zap.S().Debugf("could not list routes in namespace %q: %s", namespace, err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, routes...)
+	}
+	return result, errs
+}
+
+// PreferredGroupVersion uses discovery to find the first of the group versions that serves the resource
+func PreferredGroupVersion(client discovery.DiscoveryInterface, groupVersions []schema.GroupVersion, resource string) (schema.GroupVersion, error) {
+	for _, gv := range groupVersions {
+		resources, err := client.ServerResourcesForGroupVersion(gv.String())
+		if err != nil {
+			zap.S().Debugf("group version %q is not served: %s", gv.String(), err.Error())
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				return gv, nil
+			}
+		}
+	}
+	return schema.GroupVersion{}, fmt.Errorf("the cluster does not serve any known API version of %s", resource)
+}
+
+// discoveryCache answers ServerResourcesForGroupVersion from memory after the first call for each group version
+type discoveryCache struct {
+	discovery.DiscoveryInterface
+	mu        sync.Mutex
+	resources map[string]*metav1.APIResourceList
+	errs      map[string]error
+}
+
+// NewDiscoveryCache returns a discovery client asking the API server once for the resources of each group version,
+// so the route resolvers of every namespace of a cluster are discovered with a single round of requests
+func NewDiscoveryCache(client discovery.DiscoveryInterface) discovery.DiscoveryInterface {
+	return &discoveryCache{DiscoveryInterface: client, resources: map[string]*metav1.APIResourceList{}, errs: map[string]error{}}
+}
+
+func (c *discoveryCache) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if resources, ok := c.resources[groupVersion]; ok {
+		return resources, c.errs[groupVersion]
+	}
+	resources, err := c.DiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+	c.resources[groupVersion] = resources
+	c.errs[groupVersion] = err
+	return resources, err
+}
+
+// cachedGetter returns a func that gets objects of the resource by namespace and name,
+// remembering both hits and misses so each object is requested at most once.
+func cachedGetter(client dynamic.Interface, resource schema.GroupVersionResource) func(namespace, name string) *unstructured.Unstructured {
+	cache := map[string]*unstructured.Unstructured{}
+	return func(namespace, name string) *unstructured.Unstructured {
+		key := namespace + "/" + name
+		if obj, ok := cache[key]; ok {
+			return obj
+		}
+		obj, err := client.Resource(resource).Namespace(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			zap.S().Debugf("could not get %s %q: %s", resource.Resource, key, err.Error())
+			obj = nil
+		}
+		cache[key] = obj
+		return obj
+	}
+}
+
+// IngressResolver returns a RouteResolver that lists Ingresses of the passed resource
+func IngressResolver(client dynamic.Interface, resource schema.GroupVersionResource) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		ingresses, err := ListIngresses(client, resource, namespace)
+		if err != nil {
+			return nil, err
+		}
+		routes := []Route{}
+		for _, ing := range ingresses {
+			routes = append(routes, IngressRoutes(ing)...)
+		}
+		return routes, nil
+	}
+}
+
+// IngressRoutes converts each path of an Ingress into a Route to the path's backend
+func IngressRoutes(ing Ingress) []Route {
+	routes := []Route{}
+	for _, rule := range ing.Rules {
+		for _, path := range rule.Paths {
+			routes = append(routes, Route{
+				Kind:      KindIngress,
+				Name:      ing.Name,
+				Namespace: ing.Namespace,
+				Class:     ing.ClassName,
+				PathType:  path.PathType,
+				URLs:      []url.URL{IngressPathURL(ing, rule, path)},
+				Backends: []RouteBackend{{
+					ServiceName: path.Backend.ServiceName,
+					ServicePort: path.Backend.ServicePort,
+				}},
+			})
+		}
+	}
+
+	if ing.DefaultBackend != nil {
+		routes = append(routes, Route{
+			Kind:           KindIngress,
+			Name:           ing.Name,
+			Namespace:      ing.Namespace,
+			Class:          ing.ClassName,
+			DefaultBackend: true,
+			URLs:           IngressDefaultBackendURLs(ing),
+			Backends: []RouteBackend{{
+				ServiceName: ing.DefaultBackend.ServiceName,
+				ServicePort: ing.DefaultBackend.ServicePort,
+			}},
+		})
+	}
+	return routes
+}
+
+// ServiceBackends returns the backends of the route that forward to the service
+func (r Route) ServiceBackends(service apiv1.Service) []RouteBackend {
+	result := []RouteBackend{}
+	for _, b := range r.Backends {
+		namespace := b.Namespace
+		if len(namespace) == 0 {
+			namespace = r.Namespace
+		}
+		if namespace != service.Namespace || b.ServiceName != service.Name {
+			continue
+		}
+		if b.ServicePort != (intstr.IntOrString{}) && !ServicePortsContains(service.Spec.Ports, b.ServicePort) {
+			continue
+		}
+		if b.TargetPort != (intstr.IntOrString{}) {
+			if _, ok := FindServiceTargetPort(service.Spec.Ports, b.TargetPort); !ok {
+				continue
+			}
+		}
+		result = append(result, b)
+	}
+	return result
+}
@@ -1,41 +1,326 @@
 package k8sclient
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
 	apiv1 "k8s.io/api/core/v1"
-	v1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
-// GetServiceIngresses returns a ServiceList whose selectors match the labels on passed deployment
-func GetServiceIngresses(clientset *kubernetes.Clientset, service apiv1.Service) (*v1beta1.IngressList, error) {
-	// get all services
-	ingressClient := clientset.ExtensionsV1beta1().Ingresses(service.Namespace)
-	ingList, err := ingressClient.List(metav1.ListOptions{FieldSelector: ""})
+const (
+	// KindService is the kind reported for routes to services exposed by load balancers, node ports or external IPs
+	KindService = "Service"
+
+	// MaxNodePortAddresses limits how many node addresses are reported for NodePort services
+	MaxNodePortAddresses = 3
+)
+
+// ServiceWorkloads contains a service and its selected workloads
+type ServiceWorkloads struct {
+	Service   apiv1.Service
+	Workloads []Workload
+}
+
+// ListServices returns every service in the namespace
+func ListServices(clientset kubernetes.Interface, namespace string) ([]apiv1.Service, error) {
+	zap.S().Debugf("Getting all services in namespace %q\n", namespace)
+	services := []apiv1.Service{}
+	err := listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := clientset.CoreV1().Services(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, page.Items...)
+		return page, nil
+	})
+	return services, err
+}
+
+// GetServiceWorkloads pairs every service with the workloads it sends traffic to.
+// Membership comes from the service endpoints matched against the pods of each workload, keyed by WorkloadID.
+func GetServiceWorkloads(services []apiv1.Service, endpoints ServiceEndpoints, workloads []Workload, workloadPods map[string][]apiv1.Pod) []ServiceWorkloads {
+	index := newWorkloadIndex(workloads, workloadPods)
+
+	var result []ServiceWorkloads
+	for _, svc := range services {
+		if svc.Spec.Type == apiv1.ServiceTypeExternalName {
+			continue
+		}
+		sw := ServiceWorkloads{Service: svc}
+		// membership comes from the endpoints, the selector is only used when the service has no endpoints yet
+		if addresses := endpoints[ServiceKey(svc)]; len(addresses) != 0 {
+			sw.Workloads = index.podsWorkloads(addresses)
+		} else {
+			sw.Workloads = index.selectedWorkloads(svc)
+		}
+		result = append(result, sw)
+	}
+
+	return result
+}
+
+// SelectsWorkload is a helper for determining if a workload exists in the service's selected workloads
+func (sw *ServiceWorkloads) SelectsWorkload(workload Workload) bool {
+	return WorkloadListContains(sw.Workloads, workload)
+}
+
+// ServiceSelectsWorkload returns true if the service's selector matches the labels of the workload's pod template
+func ServiceSelectsWorkload(service apiv1.Service, workload Workload) bool {
+	if service.Spec.Type == apiv1.ServiceTypeExternalName || len(service.Spec.Selector) == 0 {
+		return false
+	}
+	if service.Namespace != workload.GetNamespace() {
+		return false
+	}
+	return labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(workload.PodTemplate().Labels))
+}
+
+// GetServiceIngresses returns the Ingresses with a path routing to the passed service
+func GetServiceIngresses(client dynamic.Interface, resource schema.GroupVersionResource, service apiv1.Service) ([]Ingress, error) {
+	// get all ingresses
+	ingList, err := ListIngresses(client, resource, service.Namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	result := &v1beta1.IngressList{Items: []v1beta1.Ingress{}}
-	for _, ing := range ingList.Items {
-		for _, rule := range ing.Spec.Rules {
-			for _, path := range rule.HTTP.Paths {
-				if service.Name == path.Backend.ServiceName && ServicePortsContains(service.Spec.Ports, path.Backend.ServicePort) {
-					result.Items = append(result.Items, ing)
-				}
-			}
+	result := []Ingress{}
+	for _, ing := range ingList {
+		if IngressRoutesTo(ing, service) {
+			result = append(result, ing)
 		}
 	}
 	return result, nil
 }
 
+// IngressRoutesTo returns true if the default backend or any path of the Ingress routes to the service
+func IngressRoutesTo(ing Ingress, service apiv1.Service) bool {
+	if ing.DefaultBackend != nil && service.Name == ing.DefaultBackend.ServiceName && ServicePortsContains(service.Spec.Ports, ing.DefaultBackend.ServicePort) {
+		return true
+	}
+	for _, rule := range ing.Rules {
+		for _, path := range rule.Paths {
+			if service.Name == path.Backend.ServiceName && ServicePortsContains(service.Spec.Ports, path.Backend.ServicePort) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ServicePortsContains is a helper for determining if a Port exists in a slice of ServicePorts
 func ServicePortsContains(servicePorts []apiv1.ServicePort, port intstr.IntOrString) bool {
+	_, ok := FindServicePort(servicePorts, port)
+	return ok
+}
+
+// FindServicePort returns the ServicePort referenced by port name or by port number
+func FindServicePort(servicePorts []apiv1.ServicePort, port intstr.IntOrString) (apiv1.ServicePort, bool) {
 	for _, p := range servicePorts {
-		if port.IntVal == p.Port {
-			return true
+		if port.Type == intstr.String && port.StrVal == p.Name {
+			return p, true
+		}
+		if port.Type == intstr.Int && port.IntVal == p.Port {
+			return p, true
 		}
 	}
-	return false
+	return apiv1.ServicePort{}, false
+}
+
+// FindServiceTargetPort returns the ServicePort whose targetPort is the port number, an unset targetPort being the port itself
+func FindServiceTargetPort(servicePorts []apiv1.ServicePort, port intstr.IntOrString) (apiv1.ServicePort, bool) {
+	for _, p := range servicePorts {
+		target := p.TargetPort
+		if target == (intstr.IntOrString{}) {
+			target = intstr.FromInt(int(p.Port))
+		}
+		if target.Type == intstr.Int && port.Type == intstr.Int && target.IntVal == port.IntVal {
+			return p, true
+		}
+	}
+	return apiv1.ServicePort{}, false
+}
+
+// ResolveTargetPort follows the service port's targetPort to the container port on the pod template.
+// An unset targetPort defaults to the service port, a named targetPort must match a named container port.
+func ResolveTargetPort(servicePort apiv1.ServicePort, template apiv1.PodTemplateSpec) (int32, error) {
+	if servicePort.TargetPort.Type == intstr.Int {
+		if servicePort.TargetPort.IntVal == 0 {
+			return servicePort.Port, nil
+		}
+		return servicePort.TargetPort.IntVal, nil
+	}
+
+	for _, c := range template.Spec.Containers {
+		for _, cp := range c.Ports {
+			if cp.Name == servicePort.TargetPort.StrVal {
+				return cp.ContainerPort, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("targetPort %q of port %d is not a named port of any container", servicePort.TargetPort.StrVal, servicePort.Port)
+}
+
+// ServiceTargetPortErrors returns an error for each port of the service whose targetPort does not resolve on the workload
+func ServiceTargetPortErrors(service apiv1.Service, workload Workload) []error {
+	errs := []error{}
+	for _, p := range service.Spec.Ports {
+		if _, err := ResolveTargetPort(p, workload.PodTemplate()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// listNodesOnce returns a func listing the nodes on its first call and returning them again on the later ones,
+// so the namespaces of a cluster share one list
+func listNodesOnce(clientset kubernetes.Interface) func() ([]apiv1.Node, error) {
+	var once sync.Once
+	var nodes []apiv1.Node
+	var err error
+	return func() ([]apiv1.Node, error) {
+		once.Do(func() {
+			var nodeList *apiv1.NodeList
+			if nodeList, err = clientset.CoreV1().Nodes().List(metav1.ListOptions{}); err == nil {
+				nodes = nodeList.Items
+			}
+		})
+		return nodes, err
+	}
+}
+
+// ExposedServiceRoutes returns the routes of the services exposed outside of the cluster.
+// listNodes is only called when there is a NodePort service, when it fails the node port routes only have the
+// external-dns hostnames of their services and its error is returned along with the routes.
+func ExposedServiceRoutes(services []apiv1.Service, listNodes func() ([]apiv1.Node, error)) ([]Route, error) {
+	nodeAddresses := []string{}
+	var err error
+	for _, svc := range services {
+		if svc.Spec.Type != apiv1.ServiceTypeNodePort {
+			continue
+		}
+		var nodes []apiv1.Node
+		if nodes, err = listNodes(); err == nil {
+			nodeAddresses = NodeAddresses(nodes, MaxNodePortAddresses)
+		}
+		break
+	}
+
+	routes := []Route{}
+	for _, svc := range services {
+		routes = append(routes, ServiceRoutes(svc, nodeAddresses)...)
+	}
+	return routes, err
+}
+
+// ServiceRoutes returns a route for each way the service is exposed outside of the cluster:
+// the external-dns hostname and load balancer ingress of LoadBalancer services, the node ports of NodePort services,
+// and the externalIPs of any service.
+func ServiceRoutes(svc apiv1.Service, nodeAddresses []string) []Route {
+	routes := []Route{}
+	newRoute := func(class string, hosts []string, nodePort bool) {
+		route := Route{
+			Kind:      KindService,
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Class:     class,
+			Backends:  []RouteBackend{{ServiceName: svc.Name}},
+		}
+		for _, host := range hosts {
+			for _, p := range svc.Spec.Ports {
+				port := p.Port
+				if nodePort {
+					port = p.NodePort
+				}
+				if port == 0 {
+					continue
+				}
+				route.URLs = append(route.URLs, url.URL{
+					Scheme: ServicePortScheme(p),
+					Host:   net.JoinHostPort(host, strconv.Itoa(int(port))),
+				})
+			}
+		}
+		if len(route.URLs) != 0 {
+			routes = append(routes, route)
+		}
+	}
+
+	switch svc.Spec.Type {
+	case apiv1.ServiceTypeLoadBalancer:
+		hosts := ServiceExternalDNSNames(svc)
+		for _, lb := range svc.Status.LoadBalancer.Ingress {
+			if len(lb.Hostname) != 0 {
+				hosts = append(hosts, lb.Hostname)
+				continue
+			}
+			hosts = append(hosts, lb.IP)
+		}
+		newRoute(string(apiv1.ServiceTypeLoadBalancer), hosts, false)
+	case apiv1.ServiceTypeNodePort:
+		newRoute(string(apiv1.ServiceTypeNodePort), append(ServiceExternalDNSNames(svc), nodeAddresses...), true)
+	}
+
+	newRoute("ExternalIP", svc.Spec.ExternalIPs, false)
+	return routes
+}
+
+// ServiceExternalDNSNames returns the hostnames of the external-dns annotation, which may be comma separated
+func ServiceExternalDNSNames(svc apiv1.Service) []string {
+	names := []string{}
+	for _, name := range strings.Split(svc.Annotations[ExternalDNSHostnameAnnotation], ",") {
+		if name = strings.Trim(strings.TrimSpace(name), "."); len(name) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ServicePortScheme guesses the URL scheme of a service port from its name and number
+func ServicePortScheme(p apiv1.ServicePort) string {
+	if p.Protocol == apiv1.ProtocolUDP {
+		return "udp"
+	}
+	name := strings.ToLower(p.Name)
+	switch {
+	case strings.HasPrefix(name, "https") || p.Port == 443:
+		return "https"
+	case strings.HasPrefix(name, "http") || p.Port == 80:
+		return "http"
+	}
+	return "tcp"
+}
+
+// NodeAddresses returns up to max node addresses, preferring each node's external IP over its internal IP
+func NodeAddresses(nodes []apiv1.Node, max int) []string {
+	addresses := []string{}
+	for _, n := range nodes {
+		if len(addresses) == max {
+			break
+		}
+		address := ""
+		for _, a := range n.Status.Addresses {
+			if a.Type == apiv1.NodeExternalIP {
+				address = a.Address
+				break
+			}
+			if a.Type == apiv1.NodeInternalIP && len(address) == 0 {
+				address = a.Address
+			}
+		}
+		if len(address) != 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
 }
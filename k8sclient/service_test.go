@@ -0,0 +1,148 @@
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestServicePortsContains(t *testing.T) {
+	ports := []apiv1.ServicePort{
+		{Name: "http", Port: 80, TargetPort: intstr.FromString("web")},
+		{Name: "metrics", Port: 9090},
+	}
+
+	tests := []struct {
+		name string
+		port intstr.IntOrString
+		want bool
+	}{
+		{name: "Number - matches the service port number", port: intstr.FromInt(80), want: true},
+		{name: "Name - matches the service port name", port: intstr.FromString("metrics"), want: true},
+		{name: "Target - the target port name is not a service port", port: intstr.FromString("web"), want: false},
+		{name: "Missing - an unknown number does not match", port: intstr.FromInt(8080), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServicePortsContains(ports, tt.port); got != tt.want {
+				t.Errorf("ServicePortsContains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTargetPort(t *testing.T) {
+	template := apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{Containers: []apiv1.Container{
+		{Name: "app", Ports: []apiv1.ContainerPort{{Name: "web", ContainerPort: 8080}}},
+	}}}
+
+	tests := []struct {
+		name    string
+		port    apiv1.ServicePort
+		want    int32
+		wantErr bool
+	}{
+		{name: "Default - an unset targetPort is the service port", port: apiv1.ServicePort{Port: 80}, want: 80},
+		{name: "Number - a numeric targetPort is used as is", port: apiv1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8081)}, want: 8081},
+		{name: "Named - a named targetPort resolves to the container port", port: apiv1.ServicePort{Port: 80, TargetPort: intstr.FromString("web")}, want: 8080},
+		{name: "Mismatch - a named targetPort missing from the containers is an error", port: apiv1.ServicePort{Port: 80, TargetPort: intstr.FromString("http")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveTargetPort(tt.port, template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveTargetPort() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveTargetPort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceRoutes(t *testing.T) {
+	ports := []apiv1.ServicePort{{Name: "https", Port: 443, NodePort: 30443}, {Name: "metrics", Port: 9090, NodePort: 30090}}
+
+	lb := apiv1.Service{Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeLoadBalancer, Ports: ports}}
+	lb.Name = "frontend"
+	lb.Annotations = map[string]string{ExternalDNSHostnameAnnotation: "shop.example.com."}
+	lb.Status.LoadBalancer.Ingress = []apiv1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+
+	nodePort := apiv1.Service{Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeNodePort, Ports: ports[:1], ExternalIPs: []string{"198.51.100.7"}}}
+	nodePort.Name = "admin"
+
+	clusterIP := apiv1.Service{Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeClusterIP, Ports: ports}}
+
+	tests := []struct {
+		name    string
+		service apiv1.Service
+		want    []string
+	}{
+		{
+			name:    "LoadBalancer - external-dns hostname and load balancer IP with each port",
+			service: lb,
+			want:    []string{"https://shop.example.com:443", "tcp://shop.example.com:9090", "https://203.0.113.10:443", "tcp://203.0.113.10:9090"},
+		},
+		{
+			name:    "NodePort - node addresses with the node port and external IPs with the service port",
+			service: nodePort,
+			want:    []string{"https://10.0.0.1:30443", "https://198.51.100.7:443"},
+		},
+		{
+			name:    "ClusterIP - internal services are not routes",
+			service: clusterIP,
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range ServiceRoutes(tt.service, []string{"10.0.0.1"}) {
+				for _, u := range r.URLs {
+					got = append(got, u.String())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServiceRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetServiceIngresses(t *testing.T) {
+	service := apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	service.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+
+	defaultBackend := testIngress("default", "fallback", "fallback.example.com", "other", int64(80))
+	defaultBackend.Object["spec"].(map[string]interface{})["defaultBackend"] = map[string]interface{}{
+		"service": map[string]interface{}{"name": "web", "port": map[string]interface{}{"number": int64(80)}},
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		testIngress("default", "by-number", "web.example.com", "web", int64(80)),
+		testIngress("default", "by-name", "www.example.com", "web", "http"),
+		testIngress("default", "wrong-port", "admin.example.com", "web", int64(8080)),
+		testIngress("default", "other-service", "api.example.com", "api", int64(80)),
+		testIngress("other", "other-namespace", "web.other.example.com", "web", int64(80)),
+		defaultBackend,
+	)
+
+	ingresses, err := GetServiceIngresses(client, IngressGroupVersions[0].WithResource("ingresses"), service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, ing := range ingresses {
+		names = append(names, ing.Name)
+	}
+	if want := []string{"by-number", "by-name", "fallback"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("GetServiceIngresses() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,135 @@
+package k8sclient
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// NewTable creates a populated table writer
+func (dips DeploymentIngressPaths) NewTable() table.Writer {
+	t := table.NewWriter()
+	clustered := dips.Clustered()
+	header := table.Row{"Workload", "Version", "Service", "Ingress"}
+	if clustered {
+		header = append(table.Row{"Cluster"}, header...)
+	}
+	t.AppendHeader(header)
+	for _, dip := range dips {
+		row := table.Row{}
+		if clustered {
+			row = append(row, dip.Cluster)
+		}
+
+		replicas := dip.Workload.Replicas()
+		depStr := []string{
+			"Name: " + dip.Workload.GetName(),
+			"Kind: " + dip.Workload.Kind(),
+			fmt.Sprintf("Ready: %d/%d", replicas.Ready, replicas.Desired),
+		}
+		for _, p := range dip.Pods {
+			podStr := p.Status.PodIP
+			// daemonset pods run one per node, so the node is more telling than the IP alone
+			if dip.Workload.Kind() == KindDaemonSet {
+				podStr = fmt.Sprintf("%s (%s)", p.Status.PodIP, p.Spec.NodeName)
+			}
+			if ready, ok := dip.PodEndpointReady(p); ok {
+				podStr = fmt.Sprintf("%s %s", podStr, readyString(ready))
+			}
+			depStr = append(depStr, podStr)
+		}
+		if dip.Workload.Kind() == KindExternal {
+			for _, a := range dip.Endpoints {
+				depStr = append(depStr, fmt.Sprintf("%s %s", a.IP, readyString(a.Ready)))
+			}
+		}
+		row = append(row, strings.Join(depStr, "\n"))
+
+		imageStr := []string{}
+		for _, container := range dip.Workload.PodTemplate().Spec.Containers {
+			imageStr = append(imageStr, container.Image)
+		}
+		row = append(row, strings.Join(imageStr, "\n"))
+
+		svcStr := []string{}
+		for _, s := range dip.Services {
+			if s.Spec.ClusterIP == apiv1.ClusterIPNone {
+				svcStr = append(svcStr, fmt.Sprintf("%s (headless)", s.ObjectMeta.Name))
+			} else {
+				svcStr = append(svcStr, fmt.Sprintf("%s", s.ObjectMeta.Name))
+			}
+			for _, err := range ServiceTargetPortErrors(s, dip.Workload) {
+				svcStr = append(svcStr, "! "+err.Error())
+			}
+		}
+		row = append(row, strings.Join(svcStr, "\n"))
+
+		ingStr := []string{}
+		for i, r := range dip.Routes {
+			// resolvers may split one object into several routes, only name it once
+			if i == 0 || dip.Routes[i-1].Kind != r.Kind || dip.Routes[i-1].Namespace != r.Namespace || dip.Routes[i-1].Name != r.Name {
+				ingStr = append(ingStr, fmt.Sprintf("%s/%s: %s", r.Kind, r.Name, r.Class))
+			}
+			if r.DefaultBackend {
+				ingStr = append(ingStr, "default backend")
+			}
+			if len(r.TLSTermination) != 0 {
+				ingStr = append(ingStr, "tls: "+r.TLSTermination)
+			}
+			for _, s := range dip.Services {
+				for _, b := range r.ServiceBackends(s) {
+					target := s.Name
+					if len(b.Subset) != 0 {
+						target = fmt.Sprintf("%s (subset %s)", s.Name, b.Subset)
+					}
+					if b.Weight != nil {
+						ingStr = append(ingStr, fmt.Sprintf("weight %d -> %s", *b.Weight, target))
+					} else if len(b.Subset) != 0 {
+						ingStr = append(ingStr, "-> "+target)
+					}
+				}
+			}
+			for _, uri := range r.URLs {
+				link, _ := url.PathUnescape(uri.String())
+				if len(r.PathType) != 0 {
+					link = fmt.Sprintf("%s (%s)", link, r.PathType)
+				}
+				ingStr = append(ingStr, link+"\n")
+			}
+		}
+		row = append(row, strings.Join(ingStr, "\n"))
+
+		t.AppendRow(row)
+	}
+	return t
+}
+
+// Clustered is whether any of the paths belongs to a named cluster
+func (dips DeploymentIngressPaths) Clustered() bool {
+	for _, dip := range dips {
+		if len(dip.Cluster) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// readyString describes whether an endpoint address receives traffic
+func readyString(ready bool) string {
+	if ready {
+		return "ready"
+	}
+	return "not ready"
+}
+
+// FPrintTable prints the DeploymentIngressPath as an ascii table
+func (dips DeploymentIngressPaths) FPrintTable(w io.Writer) {
+	t := dips.NewTable()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleLight)
+	t.Render()
+}
@@ -0,0 +1,136 @@
+package k8sclient
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// KindTraefikIngressRoute is the kind reported for routes built from Traefik IngressRoutes
+	KindTraefikIngressRoute = "IngressRoute"
+)
+
+var (
+	// TraefikGroupVersions are the Traefik CRD group versions, in order of preference
+	TraefikGroupVersions = []schema.GroupVersion{
+		{Group: "traefik.io", Version: "v1alpha1"},
+		{Group: "traefik.containo.us", Version: "v1alpha1"},
+	}
+
+	// traefikMatcher captures the matcher name and its arguments, e.g. Host(`a.example.com`)
+	traefikMatcher = regexp.MustCompile("(Host|Path|PathPrefix)\\(([^)]*)\\)")
+)
+
+// TraefikIngressRoute is the subset of a Traefik IngressRoute needed to build routes
+type TraefikIngressRoute struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		EntryPoints []string `json:"entryPoints,omitempty"`
+		Routes      []struct {
+			Match    string `json:"match"`
+			Kind     string `json:"kind,omitempty"`
+			Services []struct {
+				Name      string             `json:"name"`
+				Namespace string             `json:"namespace,omitempty"`
+				Kind      string             `json:"kind,omitempty"`
+				Port      intstr.IntOrString `json:"port,omitempty"`
+				Weight    *int32             `json:"weight,omitempty"`
+			} `json:"services,omitempty"`
+		} `json:"routes"`
+		TLS *struct {
+			SecretName   string `json:"secretName,omitempty"`
+			CertResolver string `json:"certResolver,omitempty"`
+		} `json:"tls,omitempty"`
+	} `json:"spec,omitempty"`
+}
+
+// TraefikIngressRouteResolver returns a RouteResolver that lists Traefik IngressRoutes
+func TraefikIngressRouteResolver(client dynamic.Interface, gv schema.GroupVersion) RouteResolver {
+	return func(namespace string) ([]Route, error) {
+		items, err := ListUnstructured(client.Resource(gv.WithResource("ingressroutes")).Namespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+
+		routes := []Route{}
+		for _, item := range items {
+			ir := TraefikIngressRoute{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &ir); err != nil {
+				return nil, err
+			}
+
+			scheme := "http"
+			if ir.Spec.TLS != nil {
+				scheme = "https"
+			}
+
+			for _, r := range ir.Spec.Routes {
+				route := Route{
+					Kind:      KindTraefikIngressRoute,
+					Name:      ir.Name,
+					Namespace: ir.Namespace,
+					Class:     strings.TrimSpace("traefik " + strings.Join(ir.Spec.EntryPoints, ",")),
+					URLs:      TraefikMatchURLs(scheme, r.Match),
+				}
+				for _, s := range r.Services {
+					// TraefikServices are weighted/mirrored indirections, not kubernetes services
+					if len(s.Kind) != 0 && s.Kind != "Service" {
+						continue
+					}
+					route.Backends = append(route.Backends, RouteBackend{
+						Namespace:   s.Namespace,
+						ServiceName: s.Name,
+						ServicePort: s.Port,
+						Weight:      s.Weight,
+					})
+				}
+				if len(route.Backends) == 1 {
+					route.Backends[0].Weight = nil
+				}
+				routes = append(routes, route)
+			}
+		}
+		return routes, nil
+	}
+}
+
+// TraefikMatchURLs returns a URL for each Host and Path/PathPrefix combination in a Traefik match rule.
+// Rules without a Host matcher accept any host, which is reported as `*`.
+func TraefikMatchURLs(scheme, match string) []url.URL {
+	hosts := []string{}
+	paths := []string{}
+	for _, m := range traefikMatcher.FindAllStringSubmatch(match, -1) {
+		for _, arg := range strings.Split(m[2], ",") {
+			arg = strings.Trim(strings.TrimSpace(arg), "`\"'")
+			if len(arg) == 0 {
+				continue
+			}
+			if m[1] == "Host" {
+				hosts = append(hosts, arg)
+				continue
+			}
+			paths = append(paths, arg)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = append(hosts, "*")
+	}
+	if len(paths) == 0 {
+		paths = append(paths, "/")
+	}
+
+	urls := []url.URL{}
+	for _, host := range hosts {
+		for _, path := range paths {
+			urls = append(urls, url.URL{Scheme: scheme, Host: host, Path: path})
+		}
+	}
+	return urls
+}
@@ -0,0 +1,132 @@
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTraefikMatchURLs(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		match  string
+		want   []string
+	}{
+		{
+			name:   "Host and PathPrefix - a single host and prefix",
+			scheme: "https",
+			match:  "Host(`whoami.example.com`) && PathPrefix(`/api`)",
+			want:   []string{"https://whoami.example.com/api"},
+		},
+		{
+			name:   "Multiple hosts - v2 style comma separated host arguments",
+			scheme: "http",
+			match:  "Host(`a.example.com`, `b.example.com`)",
+			want:   []string{"http://a.example.com/", "http://b.example.com/"},
+		},
+		{
+			name:   "Or - v3 style alternatives with exact paths",
+			scheme: "http",
+			match:  "(Host(`a.example.com`) || Host(`b.example.com`)) && Path(`/healthz`)",
+			want:   []string{"http://a.example.com/healthz", "http://b.example.com/healthz"},
+		},
+		{
+			name:   "No host - HostRegexp is not expanded and any host is reported",
+			scheme: "http",
+			match:  "HostRegexp(`{sub:[a-z]+}.example.com`) && PathPrefix(`/`)",
+			want:   []string{"http://*/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, u := range TraefikMatchURLs(tt.scheme, tt.match) {
+				got = append(got, u.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TraefikMatchURLs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraefikIngressRouteResolver(t *testing.T) {
+	gv := TraefikGroupVersions[0]
+	ingressRoute := func(name string, entryPoints []interface{}, tls bool, routes ...interface{}) map[string]interface{} {
+		spec := map[string]interface{}{"entryPoints": entryPoints, "routes": routes}
+		if tls {
+			spec["tls"] = map[string]interface{}{"certResolver": "letsencrypt"}
+		}
+		return map[string]interface{}{
+			"apiVersion": gv.String(),
+			"kind":       "IngressRoute",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec":       spec,
+		}
+	}
+	route := func(match string, services ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"match": match, "kind": "Rule", "services": services}
+	}
+	service := func(namespace, name string, port interface{}, weight int64) map[string]interface{} {
+		s := map[string]interface{}{"name": name, "port": port, "weight": weight}
+		if len(namespace) != 0 {
+			s["namespace"] = namespace
+		}
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		routes []map[string]interface{}
+		want   []string
+	}{
+		{
+			name: "TLS - the entry points are the class and the weight of a single service is dropped",
+			routes: []map[string]interface{}{
+				ingressRoute("whoami", []interface{}{"websecure"}, true, route("Host(`whoami.example.com`) && PathPrefix(`/api`)", service("", "whoami", int64(80), 3))),
+			},
+			want: []string{"whoami traefik websecure [https://whoami.example.com/api] -> [whoami:80]"},
+		},
+		{
+			name: "Split traffic - weights are kept and services may live in another namespace",
+			routes: []map[string]interface{}{
+				ingressRoute("web", nil, false, route("Host(`web.example.com`)", service("frontend", "web", "http", 3), service("", "web-canary", int64(80), 1))),
+			},
+			want: []string{"web traefik [http://web.example.com/] -> [frontend/web:http=3 web-canary:80=1]"},
+		},
+		{
+			name: "Routes - each route of an IngressRoute is its own route",
+			routes: []map[string]interface{}{
+				ingressRoute("shop", []interface{}{"web", "websecure"}, false,
+					route("Host(`shop.example.com`)", service("", "web", int64(80), 1)),
+					route("Host(`shop.example.com`) && Path(`/healthz`)", service("", "health", int64(8080), 1)),
+				),
+			},
+			want: []string{
+				"shop traefik web,websecure [http://shop.example.com/] -> [web:80]",
+				"shop traefik web,websecure [http://shop.example.com/healthz] -> [health:8080]",
+			},
+		},
+		{
+			name: "TraefikService - weighted indirections are not kubernetes services",
+			routes: []map[string]interface{}{
+				ingressRoute("wrr", nil, false, route("PathPrefix(`/`)", map[string]interface{}{"name": "wrr", "kind": "TraefikService"})),
+			},
+			want: []string{"wrr traefik [http://*/] -> []"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testDynamicClient(t, gv, map[string][]map[string]interface{}{"ingressroutes": tt.routes})
+			routes, err := TraefikIngressRouteResolver(client, gv)("default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := testRouteSummaries(routes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TraefikIngressRouteResolver() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package k8sclient
+
+import (
+	"go.uber.org/zap"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// KindDeployment is the kind reported for Deployment workloads
+	KindDeployment = "Deployment"
+
+	// KindStatefulSet is the kind reported for StatefulSet workloads
+	KindStatefulSet = "StatefulSet"
+
+	// KindDaemonSet is the kind reported for DaemonSet workloads
+	KindDaemonSet = "DaemonSet"
+
+	// KindReplicaSet is the kind reported for ReplicaSet workloads that are not owned by a Deployment
+	KindReplicaSet = "ReplicaSet"
+
+	// KindPod is the kind reported for bare Pods that are not owned by a controller
+	KindPod = "Pod"
+
+	// KindExternal is the kind reported for selectorless services whose endpoints are outside of the cluster
+	KindExternal = "External"
+)
+
+var (
+	// WorkloadResources are the resources ListWorkloads lists, in the order it lists them
+	WorkloadResources = []string{"deployments", "statefulsets", "daemonsets", "replicasets"}
+)
+
+// Workload is anything that runs pods which services can select
+type Workload interface {
+	metav1.Object
+
+	// Kind returns the kind of the underlying object, e.g. Deployment
+	Kind() string
+	// PodSelector returns the selector used to find the workload's pods
+	PodSelector() *metav1.LabelSelector
+	// PodTemplate returns the template the workload's pods are created from
+	PodTemplate() apiv1.PodTemplateSpec
+	// Replicas returns the desired and observed replica counts
+	Replicas() Replicas
+}
+
+// Replicas is the replica status of a Workload
+type Replicas struct {
+	Desired   int32
+	Ready     int32
+	Available int32
+}
+
+// DeploymentWorkload adapts a Deployment to the Workload interface
+type DeploymentWorkload struct {
+	*v1.Deployment
+}
+
+// Kind returns KindDeployment
+func (w DeploymentWorkload) Kind() string { return KindDeployment }
+
+// PodSelector returns spec.selector
+func (w DeploymentWorkload) PodSelector() *metav1.LabelSelector { return w.Spec.Selector }
+
+// PodTemplate returns spec.template
+func (w DeploymentWorkload) PodTemplate() apiv1.PodTemplateSpec { return w.Spec.Template }
+
+// Replicas returns the deployment's replica status
+func (w DeploymentWorkload) Replicas() Replicas {
+	desired := int32(1)
+	if w.Spec.Replicas != nil {
+		desired = *w.Spec.Replicas
+	}
+	return Replicas{Desired: desired, Ready: w.Status.ReadyReplicas, Available: w.Status.AvailableReplicas}
+}
+
+// StatefulSetWorkload adapts a StatefulSet to the Workload interface
+type StatefulSetWorkload struct {
+	*v1.StatefulSet
+}
+
+// Kind returns KindStatefulSet
+func (w StatefulSetWorkload) Kind() string { return KindStatefulSet }
+
+// PodSelector returns spec.selector
+func (w StatefulSetWorkload) PodSelector() *metav1.LabelSelector { return w.Spec.Selector }
+
+// PodTemplate returns spec.template
+func (w StatefulSetWorkload) PodTemplate() apiv1.PodTemplateSpec { return w.Spec.Template }
+
+// Replicas returns the statefulset's replica status
+func (w StatefulSetWorkload) Replicas() Replicas {
+	desired := int32(1)
+	if w.Spec.Replicas != nil {
+		desired = *w.Spec.Replicas
+	}
+	// statefulsets do not report available replicas, ready is the closest equivalent
+	return Replicas{Desired: desired, Ready: w.Status.ReadyReplicas, Available: w.Status.ReadyReplicas}
+}
+
+// DaemonSetWorkload adapts a DaemonSet to the Workload interface
+type DaemonSetWorkload struct {
+	*v1.DaemonSet
+}
+
+// Kind returns KindDaemonSet
+func (w DaemonSetWorkload) Kind() string { return KindDaemonSet }
+
+// PodSelector returns spec.selector
+func (w DaemonSetWorkload) PodSelector() *metav1.LabelSelector { return w.Spec.Selector }
+
+// PodTemplate returns spec.template
+func (w DaemonSetWorkload) PodTemplate() apiv1.PodTemplateSpec { return w.Spec.Template }
+
+// Replicas returns the daemonset's scheduling status, one replica per eligible node
+func (w DaemonSetWorkload) Replicas() Replicas {
+	return Replicas{Desired: w.Status.DesiredNumberScheduled, Ready: w.Status.NumberReady, Available: w.Status.NumberAvailable}
+}
+
+// ReplicaSetWorkload adapts a ReplicaSet to the Workload interface
+type ReplicaSetWorkload struct {
+	*v1.ReplicaSet
+}
+
+// Kind returns KindReplicaSet
+func (w ReplicaSetWorkload) Kind() string { return KindReplicaSet }
+
+// PodSelector returns spec.selector
+func (w ReplicaSetWorkload) PodSelector() *metav1.LabelSelector { return w.Spec.Selector }
+
+// PodTemplate returns spec.template
+func (w ReplicaSetWorkload) PodTemplate() apiv1.PodTemplateSpec { return w.Spec.Template }
+
+// Replicas returns the replicaset's replica status
+func (w ReplicaSetWorkload) Replicas() Replicas {
+	desired := int32(1)
+	if w.Spec.Replicas != nil {
+		desired = *w.Spec.Replicas
+	}
+	return Replicas{Desired: desired, Ready: w.Status.ReadyReplicas, Available: w.Status.AvailableReplicas}
+}
+
+// PodWorkload adapts a bare Pod to the Workload interface
+type PodWorkload struct {
+	*apiv1.Pod
+}
+
+// Kind returns KindPod
+func (w PodWorkload) Kind() string { return KindPod }
+
+// PodSelector returns a selector matching the pod's own labels
+func (w PodWorkload) PodSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: w.Labels}
+}
+
+// PodTemplate returns the pod's metadata and spec as a template
+func (w PodWorkload) PodTemplate() apiv1.PodTemplateSpec {
+	return apiv1.PodTemplateSpec{ObjectMeta: w.ObjectMeta, Spec: w.Spec}
+}
+
+// Replicas returns a single replica that is ready when the pod's Ready condition is true
+func (w PodWorkload) Replicas() Replicas {
+	r := Replicas{Desired: 1}
+	if PodReady(*w.Pod) {
+		r.Ready = 1
+		r.Available = 1
+	}
+	return r
+}
+
+// ExternalWorkload adapts the manually managed endpoints of a selectorless Service to the Workload interface
+type ExternalWorkload struct {
+	*apiv1.Service
+	Addresses []EndpointAddress
+}
+
+// Kind returns KindExternal
+func (w ExternalWorkload) Kind() string { return KindExternal }
+
+// PodSelector returns nil as external endpoints have no pods
+func (w ExternalWorkload) PodSelector() *metav1.LabelSelector { return nil }
+
+// PodTemplate returns an empty template as external endpoints have no pods
+func (w ExternalWorkload) PodTemplate() apiv1.PodTemplateSpec { return apiv1.PodTemplateSpec{} }
+
+// Replicas counts each address as a replica
+func (w ExternalWorkload) Replicas() Replicas {
+	r := Replicas{Desired: int32(len(w.Addresses))}
+	for _, a := range w.Addresses {
+		if a.Ready {
+			r.Ready++
+			r.Available++
+		}
+	}
+	return r
+}
+
+// PodReady returns true if the pod's Ready condition is true
+func PodReady(pod apiv1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// ListWorkloads returns every Deployment, StatefulSet and DaemonSet in the namespace,
+// along with the ReplicaSets that are not managed by another controller.
+// Bare pods are read from the namespace's pods with BarePodWorkloads so pods are only listed once.
+// A kind that cannot be listed, e.g. statefulsets under a Role that only grants deployments, is left out
+// and its error returned by resource, see WorkloadResources, so the other kinds are still reported.
+func ListWorkloads(clientset kubernetes.Interface, namespace string) ([]Workload, map[string]error) {
+	workloads := []Workload{}
+	errs := map[string]error{}
+
+	zap.S().Debugf("Listing deployments in namespace %q\n", namespace)
+	kind := []Workload{}
+	err := listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := clientset.AppsV1().Deployments(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Items {
+			kind = append(kind, DeploymentWorkload{&page.Items[i]})
+		}
+		return page, nil
+	})
+	if err != nil {
+		errs["deployments"] = err
+	} else {
+		workloads = append(workloads, kind...)
+	}
+
+	zap.S().Debugf("Listing statefulsets in namespace %q\n", namespace)
+	kind = []Workload{}
+	err = listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := clientset.AppsV1().StatefulSets(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Items {
+			kind = append(kind, StatefulSetWorkload{&page.Items[i]})
+		}
+		return page, nil
+	})
+	if err != nil {
+		errs["statefulsets"] = err
+	} else {
+		workloads = append(workloads, kind...)
+	}
+
+	zap.S().Debugf("Listing daemonsets in namespace %q\n", namespace)
+	kind = []Workload{}
+	err = listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := clientset.AppsV1().DaemonSets(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Items {
+			kind = append(kind, DaemonSetWorkload{&page.Items[i]})
+		}
+		return page, nil
+	})
+	if err != nil {
+		errs["daemonsets"] = err
+	} else {
+		workloads = append(workloads, kind...)
+	}
+
+	zap.S().Debugf("Listing replicasets in namespace %q\n", namespace)
+	kind = []Workload{}
+	err = listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := clientset.AppsV1().ReplicaSets(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Items {
+			// replicasets managed by a deployment are already represented by that deployment
+			if metav1.GetControllerOf(&page.Items[i]) != nil {
+				continue
+			}
+			kind = append(kind, ReplicaSetWorkload{&page.Items[i]})
+		}
+		return page, nil
+	})
+	if err != nil {
+		errs["replicasets"] = err
+	} else {
+		workloads = append(workloads, kind...)
+	}
+
+	return workloads, errs
+}
+
+// ListPods returns every pod in the namespace
+func ListPods(clientset kubernetes.Interface, namespace string) ([]apiv1.Pod, error) {
+	zap.S().Debugf("Listing pods in namespace %q\n", namespace)
+	pods := []apiv1.Pod{}
+	err := listPages(func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		page, err := clientset.CoreV1().Pods(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, page.Items...)
+		return page, nil
+	})
+	return pods, err
+}
+
+// BarePodWorkloads returns a PodWorkload for each pod that is not managed by a controller
+func BarePodWorkloads(pods []apiv1.Pod) []Workload {
+	workloads := []Workload{}
+	for i := range pods {
+		if metav1.GetControllerOf(&pods[i]) != nil {
+			continue
+		}
+		workloads = append(workloads, PodWorkload{&pods[i]})
+	}
+	return workloads
+}
+
+// WorkloadID identifies the workload by kind, namespace and name. Workloads are not joined by UID
+// as objects created by hand, e.g. in the fake clientset, have none.
+func WorkloadID(workload Workload) string {
+	return workload.Kind() + "/" + NamespaceNameKey(workload)
+}
+
+// WorkloadListContains is a helper for determining if a workload exists in a slice of workloads.
+// Workloads are compared by UID, which is unique across kinds and namespaces.
+func WorkloadListContains(workloads []Workload, workload Workload) bool {
+	for _, w := range workloads {
+		if WorkloadID(w) == WorkloadID(workload) {
+			return true
+		}
+	}
+	return false
+}
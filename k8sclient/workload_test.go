@@ -0,0 +1,71 @@
+package k8sclient
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServiceSelectsWorkload(t *testing.T) {
+	template := apiv1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", "tier": "frontend"}}}
+	deployment := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	deployment.Spec.Template = template
+	statefulSet := &v1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	statefulSet.Spec.Template = apiv1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}}}
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default", Labels: map[string]string{"app": "web"}}}
+
+	service := func(namespace string, selector map[string]string) apiv1.Service {
+		return apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: namespace},
+			Spec:       apiv1.ServiceSpec{Selector: selector},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		service  apiv1.Service
+		workload Workload
+		want     bool
+	}{
+		{
+			name:     "Deployment - a subset of the template labels selects the deployment",
+			service:  service("default", map[string]string{"app": "web"}),
+			workload: DeploymentWorkload{deployment},
+			want:     true,
+		},
+		{
+			name:     "StatefulSet - a different label value does not select the statefulset",
+			service:  service("default", map[string]string{"app": "web"}),
+			workload: StatefulSetWorkload{statefulSet},
+			want:     false,
+		},
+		{
+			name:     "Pod - a bare pod is selected by its own labels",
+			service:  service("default", map[string]string{"app": "web"}),
+			workload: PodWorkload{pod},
+			want:     true,
+		},
+		{
+			name:     "Namespace - services only select workloads in their namespace",
+			service:  service("other", map[string]string{"app": "web"}),
+			workload: DeploymentWorkload{deployment},
+			want:     false,
+		},
+		{
+			name:     "Selectorless - a service without a selector selects nothing",
+			service:  service("default", nil),
+			workload: DeploymentWorkload{deployment},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServiceSelectsWorkload(tt.service, tt.workload); got != tt.want {
+				t.Errorf("ServiceSelectsWorkload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
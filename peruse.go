@@ -0,0 +1,212 @@
+// Package peruse maps the workloads in a kubernetes cluster to the services that send them traffic
+// and the routes, e.g. Ingresses, Gateway API HTTPRoutes or LoadBalancer services, that expose them.
+//
+// Embed it by building a Peruse with New and taking a Snapshot:
+//
+//	p, err := peruse.New(peruse.Options{Config: restConfig, Namespaces: []string{"shop"}})
+//	if err != nil {
+//		return err
+//	}
+//	topology, err := p.Snapshot(ctx)
+//
+// The Topology returned by Snapshot is safe to serialize as JSON.
+// Lower level building blocks are in the k8sclient package.
+package peruse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xortim/peruse/k8sclient"
+	"go.uber.org/zap"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// WorkloadKinds are the kinds of workloads Snapshot reports
+	WorkloadKinds = []string{
+		k8sclient.KindDeployment,
+		k8sclient.KindStatefulSet,
+		k8sclient.KindDaemonSet,
+		k8sclient.KindReplicaSet,
+		k8sclient.KindPod,
+		k8sclient.KindExternal,
+	}
+)
+
+// Cluster is one of several clusters a Peruse reads, set either Config or both clients
+type Cluster struct {
+	// Name is reported as the Cluster of the cluster's workloads and must be unique
+	Name string
+	// Config is used to build the clients when Clientset and DynamicClient are not set
+	Config        *rest.Config
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+}
+
+// Options configure a Peruse. Only the cluster to talk to is required, set either Config or both clients, or Clusters.
+type Options struct {
+	// Config is used to build the clients when Clientset and DynamicClient are not set
+	Config        *rest.Config
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+	// Clusters are read concurrently instead of the single unnamed cluster of Config and the clients.
+	// A cluster that cannot be read is named in the Warnings of the Topology.
+	Clusters []Cluster
+
+	// Namespaces limits the snapshot to these namespaces, every namespace when empty
+	Namespaces []string
+	// ExcludeNamespaces are left out of the snapshot, e.g. kube-system
+	ExcludeNamespaces []string
+	// NamespaceSelector is a label selector namespaces must match to be read, it requires listing namespaces
+	NamespaceSelector string
+	// FallbackNamespaces are tried when every namespace is read but listing cluster wide and listing namespaces are forbidden
+	FallbackNamespaces []string
+	// Selector is a label selector, e.g. `team=shop,tier!=batch`, workloads must match to be reported
+	Selector string
+	// WorkloadKinds limits the snapshot to these kinds of workloads, every kind in WorkloadKinds when empty
+	WorkloadKinds []string
+	// RouteResolvers returns the resolvers of a cluster in place of k8sclient.DefaultRouteResolvers
+	RouteResolvers func(cluster k8sclient.Cluster) []k8sclient.RouteResolver
+	// RouteMappings add resolvers for custom resources to the route resolvers
+	RouteMappings []k8sclient.RouteMapping
+
+	// Logger receives the messages of the Peruse itself, e.g. the warnings of a partial snapshot, zap's global logger when nil.
+	// The clients reading the clusters always log to zap's global logger, see zap.ReplaceGlobals.
+	Logger *zap.Logger
+}
+
+// Peruse takes snapshots of the topology of one or more clusters
+type Peruse struct {
+	clusters  []k8sclient.Cluster
+	filter    k8sclient.Filter
+	fallback  []string
+	kinds     map[string]bool
+	resolvers func(cluster k8sclient.Cluster) []k8sclient.RouteResolver
+	// custom are the resolvers of the route mappings by cluster name
+	custom map[string][]k8sclient.RouteResolver
+	log    *zap.SugaredLogger
+}
+
+// New validates the options and returns a Peruse
+func New(opts Options) (*Peruse, error) {
+	p := &Peruse{
+		fallback:  opts.FallbackNamespaces,
+		kinds:     map[string]bool{},
+		resolvers: opts.RouteResolvers,
+		custom:    map[string][]k8sclient.RouteResolver{},
+		log:       zap.S(),
+	}
+	if opts.Logger != nil {
+		p.log = opts.Logger.Sugar()
+	}
+
+	clusters := opts.Clusters
+	if len(clusters) == 0 {
+		clusters = []Cluster{{Config: opts.Config, Clientset: opts.Clientset, DynamicClient: opts.DynamicClient}}
+	}
+	for _, c := range clusters {
+		cluster, err := newCluster(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.custom[cluster.Name]; ok {
+			return nil, fmt.Errorf("cluster %q is configured more than once", cluster.Name)
+		}
+		if p.custom[cluster.Name], err = k8sclient.CustomRouteResolvers(cluster.DynamicClient, opts.RouteMappings); err != nil {
+			return nil, err
+		}
+		p.clusters = append(p.clusters, cluster)
+	}
+
+	filter, err := k8sclient.NewFilter(opts.Namespaces, opts.ExcludeNamespaces, opts.NamespaceSelector, opts.Selector)
+	if err != nil {
+		return nil, err
+	}
+	p.filter = filter
+
+	kinds := opts.WorkloadKinds
+	if len(kinds) == 0 {
+		kinds = WorkloadKinds
+	}
+	for _, kind := range kinds {
+		if !contains(WorkloadKinds, kind) {
+			return nil, fmt.Errorf("unknown workload kind %q, expected one of %v", kind, WorkloadKinds)
+		}
+		p.kinds[kind] = true
+	}
+
+	return p, nil
+}
+
+// newCluster builds the clients of the cluster that are not set
+func newCluster(c Cluster) (k8sclient.Cluster, error) {
+	cluster := k8sclient.Cluster{Name: c.Name, Clientset: c.Clientset, DynamicClient: c.DynamicClient}
+	if cluster.Clientset != nil && cluster.DynamicClient != nil {
+		return cluster, nil
+	}
+	if c.Config == nil {
+		return cluster, k8sclient.ClusterError{Cluster: c.Name, Err: fmt.Errorf("either a config or both a clientset and dynamic client are required")}
+	}
+	var err error
+	if cluster.Clientset == nil {
+		if cluster.Clientset, err = kubernetes.NewForConfig(c.Config); err != nil {
+			return cluster, k8sclient.ClusterError{Cluster: c.Name, Err: err}
+		}
+	}
+	if cluster.DynamicClient == nil {
+		if cluster.DynamicClient, err = dynamic.NewForConfig(c.Config); err != nil {
+			return cluster, k8sclient.ClusterError{Cluster: c.Name, Err: err}
+		}
+	}
+	return cluster, nil
+}
+
+// Snapshot lists the configured namespaces of every cluster concurrently and returns their topology.
+// Whatever cannot be read, a cluster, a namespace or a resource, is named in the Warnings of the topology
+// and left out of it. Snapshot only fails when nothing can be read.
+// Cancelling the context stops the snapshot between namespaces.
+func (p *Peruse) Snapshot(ctx context.Context) (*Topology, error) {
+	result, err := k8sclient.GetClustersDeploymentIngressPaths(p.clusters, func(cluster k8sclient.Cluster) (k8sclient.Result, error) {
+		// the APIs a cluster serves are discovered on every snapshot so a cluster that was unreachable recovers fully
+		var resolvers []k8sclient.RouteResolver
+		if p.resolvers != nil {
+			resolvers = p.resolvers(cluster)
+		} else {
+			resolvers = k8sclient.DefaultRouteResolvers(cluster.Clientset, cluster.DynamicClient)
+		}
+		resolvers = append(resolvers, p.custom[cluster.Name]...)
+
+		p.log.Debugf("Taking a snapshot of cluster %q", cluster.Name)
+		return k8sclient.GetFilteredDeploymentIngressPathsContext(ctx, cluster.Clientset, cluster.DynamicClient, resolvers, p.filter, p.fallback)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range result.Warnings {
+		p.log.Warnf("partial snapshot: %s", w.String())
+	}
+	dips := k8sclient.DeploymentIngressPaths{}
+	for _, dip := range result.Paths {
+		if p.kinds[dip.Workload.Kind()] {
+			dips = append(dips, dip)
+		}
+	}
+	result.Paths = dips
+	return NewTopology(result), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
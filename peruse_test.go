@@ -0,0 +1,160 @@
+package peruse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xortim/peruse/k8sclient"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	web := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web", Labels: map[string]string{"team": "shop"}}}
+	web.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	web.Spec.Template.Labels = map[string]string{"app": "web"}
+	web.Spec.Template.Spec.Containers = []apiv1.Container{{Name: "web", Image: "shop/web:1.4.2"}}
+
+	worker := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "shop", UID: "worker", Labels: map[string]string{"team": "batch"}}}
+	worker.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "worker"}}
+	worker.Spec.Template.Labels = map[string]string{"app": "worker"}
+
+	db := &v1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop", UID: "db", Labels: map[string]string{"team": "shop"}}}
+	db.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	db.Spec.Template.Labels = map[string]string{"app": "db"}
+
+	svc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web-svc"}}
+	svc.Spec.Type = apiv1.ServiceTypeLoadBalancer
+	svc.Spec.Selector = map[string]string{"app": "web"}
+	svc.Spec.Ports = []apiv1.ServicePort{{Name: "http", Port: 80}}
+	svc.Status.LoadBalancer.Ingress = []apiv1.LoadBalancerIngress{{Hostname: "shop.example.com"}}
+
+	clientset := fake.NewSimpleClientset(web, worker, db, svc)
+	p, err := New(Options{
+		Clientset:     clientset,
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		Namespaces:    []string{"shop"},
+		Selector:      "team=shop",
+		WorkloadKinds: []string{k8sclient.KindDeployment},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topology, err := p.Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topology.Workloads) != 1 {
+		t.Fatalf("Snapshot() got %d workloads, want only the web deployment", len(topology.Workloads))
+	}
+
+	w := topology.Workloads[0]
+	if w.Name != "web" || !reflect.DeepEqual(w.Images, []string{"shop/web:1.4.2"}) || len(w.Services) != 1 || w.Services[0].Name != "web" {
+		t.Errorf("Snapshot() workload = %+v, want web with its image and service", w)
+	}
+	if len(w.Routes) != 1 || !reflect.DeepEqual(w.Routes[0].URLs, []string{"http://shop.example.com:80"}) {
+		t.Errorf("Snapshot() routes = %+v, want the load balancer", w.Routes)
+	}
+
+	// the topology is meant to be handed to other tools as JSON
+	out, err := json.Marshal(topology)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Topology{}
+	if err := json.Unmarshal(out, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Workloads, topology.Workloads) {
+		t.Errorf("Topology does not survive a JSON round trip:\n%s", out)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Snapshot(ctx); err != context.Canceled {
+		t.Errorf("Snapshot() with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSnapshotClusters(t *testing.T) {
+	web := &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "web"}}
+	web.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	web.Spec.Template.Labels = map[string]string{"app": "web"}
+
+	unreachable := fake.NewSimpleClientset()
+	unreachable.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	reachable := func() *fake.Clientset {
+		clientset := fake.NewSimpleClientset(web.DeepCopy())
+		clientset.Resources = []*metav1.APIResourceList{
+			{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses", Namespaced: true, Kind: "Ingress"}}},
+		}
+		return clientset
+	}
+
+	p, err := New(Options{Clusters: []Cluster{
+		{Name: "staging", Clientset: reachable(), DynamicClient: dynamicClient},
+		{Name: "edge", Clientset: unreachable, DynamicClient: dynamicClient},
+		{Name: "production", Clientset: reachable(), DynamicClient: dynamicClient},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topology, err := p.Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters := []string{}
+	for _, w := range topology.Workloads {
+		clusters = append(clusters, w.Cluster+"/"+w.Name)
+	}
+	if want := []string{"staging/web", "production/web"}; !reflect.DeepEqual(clusters, want) {
+		t.Errorf("Snapshot() workloads = %v, want %v", clusters, want)
+	}
+	if len(topology.Warnings) != 1 || topology.Warnings[0].Cluster != "edge" || len(topology.Warnings[0].Namespace) != 0 {
+		t.Errorf("Snapshot() warnings = %+v, want the edge cluster", topology.Warnings)
+	}
+
+	if _, err := New(Options{Clusters: []Cluster{
+		{Name: "staging", Clientset: unreachable, DynamicClient: dynamicClient},
+		{Name: "staging", Clientset: unreachable, DynamicClient: dynamicClient},
+	}}); err == nil {
+		t.Error("New() should reject clusters with the same name")
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Clients - a config or clients are required", opts: Options{}},
+		{name: "Selector - invalid selectors are rejected", opts: Options{Clientset: clientset, DynamicClient: dynamicClient, Selector: "team in (shop"}},
+		{name: "Kinds - unknown kinds are rejected", opts: Options{Clientset: clientset, DynamicClient: dynamicClient, WorkloadKinds: []string{"CronJob"}}},
+		{name: "Mappings - invalid route mappings are rejected", opts: Options{Clientset: clientset, DynamicClient: dynamicClient, RouteMappings: []k8sclient.RouteMapping{{Version: "v1"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); err == nil {
+				t.Error("New() should fail")
+			}
+		})
+	}
+}
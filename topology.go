@@ -0,0 +1,242 @@
+package peruse
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/xortim/peruse/k8sclient"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// APIVersion is the version of the schema of Topology and WorkloadRecord, it changes when a field is renamed or removed
+	APIVersion = "peruse/v1"
+)
+
+// Topology is a point in time view of the workloads in one or more clusters, the services that send them traffic
+// and the routes that expose those services
+type Topology struct {
+	APIVersion string     `json:"apiVersion"`
+	Time       time.Time  `json:"time"`
+	Workloads  []Workload `json:"workloads"`
+	// Warnings name what could not be read, e.g. an unreachable cluster, a namespace that may not be read or a forbidden resource.
+	// The workloads are partial when there are any.
+	Warnings []Warning `json:"warnings"`
+}
+
+// Warning names something that could not be read
+type Warning struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Resource is what could not be read, e.g. deployments or routes, empty when the whole cluster or namespace is missing
+	Resource string `json:"resource,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Workload is anything that runs pods, or the external addresses of a selectorless service
+type Workload struct {
+	// Cluster is the name of the cluster the workload runs in, empty when only one cluster is read
+	Cluster   string            `json:"cluster,omitempty"`
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Replicas  Replicas          `json:"replicas"`
+	// Images are the container images of the pod template
+	Images    []string   `json:"images"`
+	Pods      []Pod      `json:"pods"`
+	Services  []Service  `json:"services"`
+	Endpoints []Endpoint `json:"endpoints"`
+	Routes    []Route    `json:"routes"`
+}
+
+// WorkloadRecord is a Workload along with the version of its schema, as written one per line by `peruse -o jsonl`
+type WorkloadRecord struct {
+	APIVersion string `json:"apiVersion"`
+	Workload
+}
+
+// Replicas is the desired and observed replica counts of a Workload
+type Replicas struct {
+	Desired   int32 `json:"desired"`
+	Ready     int32 `json:"ready"`
+	Available int32 `json:"available"`
+}
+
+// Pod is a pod of a Workload
+type Pod struct {
+	Name string `json:"name"`
+	IP   string `json:"ip,omitempty"`
+	Node string `json:"node,omitempty"`
+	// Ready is the pod's Ready condition
+	Ready bool `json:"ready"`
+	// EndpointReady is whether the pod receives traffic from its services, nil when it is not an endpoint of any
+	EndpointReady *bool `json:"endpointReady,omitempty"`
+}
+
+// Service is a service that sends traffic to a Workload
+type Service struct {
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	ClusterIP string        `json:"clusterIP,omitempty"`
+	Headless  bool          `json:"headless,omitempty"`
+	Ports     []ServicePort `json:"ports,omitempty"`
+	// Errors describe service ports whose targetPort the Workload does not expose
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ServicePort is a port of a Service
+type ServicePort struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol,omitempty"`
+	Port       int32  `json:"port"`
+	TargetPort string `json:"targetPort,omitempty"`
+	NodePort   int32  `json:"nodePort,omitempty"`
+}
+
+// Endpoint is an address of a Service that routes to the Workload
+type Endpoint struct {
+	IP       string `json:"ip"`
+	Hostname string `json:"hostname,omitempty"`
+	Node     string `json:"node,omitempty"`
+	Ready    bool   `json:"ready"`
+}
+
+// Route is an external entry point forwarding to the Workload's services
+type Route struct {
+	Kind           string    `json:"kind"`
+	Namespace      string    `json:"namespace"`
+	Name           string    `json:"name"`
+	Class          string    `json:"class,omitempty"`
+	TLSTermination string    `json:"tlsTermination,omitempty"`
+	PathType       string    `json:"pathType,omitempty"`
+	DefaultBackend bool      `json:"defaultBackend,omitempty"`
+	URLs           []string  `json:"urls"`
+	Backends       []Backend `json:"backends"`
+}
+
+// Backend is a service a Route forwards traffic to
+type Backend struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	// Port is empty when the route forwards to any port of the service
+	Port   string `json:"port,omitempty"`
+	Weight *int32 `json:"weight,omitempty"`
+	Subset string `json:"subset,omitempty"`
+}
+
+// NewTopology converts a Result to its serializable Topology taken now
+func NewTopology(result k8sclient.Result) *Topology {
+	topology := &Topology{APIVersion: APIVersion, Time: time.Now().UTC(), Workloads: []Workload{}, Warnings: []Warning{}}
+	for _, dip := range result.Paths {
+		topology.Workloads = append(topology.Workloads, NewWorkload(dip))
+	}
+	for _, w := range result.Warnings {
+		topology.Warnings = append(topology.Warnings, Warning{Cluster: w.Cluster, Namespace: w.Namespace, Resource: w.Resource, Message: w.Message})
+	}
+	return topology
+}
+
+// NewWorkload converts a DeploymentIngressPath to its serializable Workload
+func NewWorkload(dip k8sclient.DeploymentIngressPath) Workload {
+	replicas := dip.Workload.Replicas()
+	w := Workload{
+		Cluster:   dip.Cluster,
+		Kind:      dip.Workload.Kind(),
+		Namespace: dip.Workload.GetNamespace(),
+		Name:      dip.Workload.GetName(),
+		Labels:    dip.Workload.GetLabels(),
+		Replicas:  Replicas{Desired: replicas.Desired, Ready: replicas.Ready, Available: replicas.Available},
+		Images:    []string{},
+		Pods:      []Pod{},
+		Services:  []Service{},
+		Endpoints: []Endpoint{},
+		Routes:    []Route{},
+	}
+
+	for _, c := range dip.Workload.PodTemplate().Spec.Containers {
+		w.Images = append(w.Images, c.Image)
+	}
+
+	for _, p := range dip.Pods {
+		pod := Pod{Name: p.Name, IP: p.Status.PodIP, Node: p.Spec.NodeName, Ready: k8sclient.PodReady(p)}
+		if ready, ok := dip.PodEndpointReady(p); ok {
+			pod.EndpointReady = &ready
+		}
+		w.Pods = append(w.Pods, pod)
+	}
+
+	for _, s := range dip.Services {
+		w.Services = append(w.Services, newService(s, dip.Workload))
+	}
+
+	for _, a := range dip.Endpoints {
+		w.Endpoints = append(w.Endpoints, Endpoint{IP: a.IP, Hostname: a.Hostname, Node: a.NodeName, Ready: a.Ready})
+	}
+
+	for _, r := range dip.Routes {
+		w.Routes = append(w.Routes, newRoute(r, dip.Services))
+	}
+	return w
+}
+
+func newService(s apiv1.Service, workload k8sclient.Workload) Service {
+	svc := Service{
+		Namespace: s.Namespace,
+		Name:      s.Name,
+		Type:      string(s.Spec.Type),
+		ClusterIP: s.Spec.ClusterIP,
+		Headless:  s.Spec.ClusterIP == apiv1.ClusterIPNone,
+	}
+	for _, p := range s.Spec.Ports {
+		port := ServicePort{Name: p.Name, Protocol: string(p.Protocol), Port: p.Port, NodePort: p.NodePort}
+		if p.TargetPort != (intstr.IntOrString{}) {
+			port.TargetPort = p.TargetPort.String()
+		}
+		svc.Ports = append(svc.Ports, port)
+	}
+	for _, err := range k8sclient.ServiceTargetPortErrors(s, workload) {
+		svc.Errors = append(svc.Errors, err.Error())
+	}
+	return svc
+}
+
+// newRoute converts a route, keeping only the backends that forward to one of the services
+func newRoute(r k8sclient.Route, services []apiv1.Service) Route {
+	route := Route{
+		Kind:           r.Kind,
+		Namespace:      r.Namespace,
+		Name:           r.Name,
+		Class:          r.Class,
+		TLSTermination: r.TLSTermination,
+		PathType:       r.PathType,
+		DefaultBackend: r.DefaultBackend,
+		URLs:           []string{},
+		Backends:       []Backend{},
+	}
+	for _, u := range r.URLs {
+		// wildcard hosts and paths are escaped by url.URL, report them as written
+		link, err := url.PathUnescape(u.String())
+		if err != nil {
+			link = u.String()
+		}
+		route.URLs = append(route.URLs, link)
+	}
+	for _, s := range services {
+		for _, b := range r.ServiceBackends(s) {
+			backend := Backend{Namespace: s.Namespace, Service: b.ServiceName, Weight: b.Weight, Subset: b.Subset}
+			if b.ServicePort != (intstr.IntOrString{}) {
+				backend.Port = b.ServicePort.String()
+			}
+			// OpenShift Routes name the port of the pods, report the service port forwarding to it
+			if p, ok := k8sclient.FindServiceTargetPort(s.Spec.Ports, b.TargetPort); ok {
+				backend.Port = strconv.Itoa(int(p.Port))
+			}
+			route.Backends = append(route.Backends, backend)
+		}
+	}
+	return route
+}